@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Accounts own saved characters. Logging in binds an account to a fresh
+// session, and saves and loads only ever touch that account's characters.
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var (
+	errUsernameTaken = errors.New("username taken")
+	errInvalidLogin  = errors.New("invalid username or password")
+)
+
+// createAccount stores a new account with a hash of its password and
+// returns its ID.
+func createAccount(username, password string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(`INSERT INTO accounts (username, password_hash) VALUES (?, ?);`, username, string(hash))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, errUsernameTaken
+		}
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// authenticate checks a username and password and returns the account's ID.
+func authenticate(username, password string) (int64, error) {
+	var id int64
+	var hash string
+	err := db.QueryRow(`SELECT id, password_hash FROM accounts WHERE username = ?;`, username).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return 0, errInvalidLogin
+	}
+	if err != nil {
+		return 0, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return 0, errInvalidLogin
+	}
+	return id, nil
+}
+
+// RegisterHandler creates an account and logs it in.
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	credentials.Username = strings.TrimSpace(credentials.Username)
+	if len(credentials.Username) < minUsernameLength || len(credentials.Username) > maxUsernameLength {
+		http.Error(w, "Username must be 3 to 32 characters", http.StatusBadRequest)
+		return
+	}
+	if len(credentials.Password) < minPasswordLength || len(credentials.Password) > maxPasswordLength {
+		http.Error(w, "Password must be 8 to 72 characters", http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := createAccount(credentials.Username, credentials.Password)
+	if err == errUsernameTaken {
+		http.Error(w, "Username already taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error creating account", http.StatusInternalServerError)
+		return
+	}
+
+	startAccountSession(w, r, accountID, credentials.Username)
+}
+
+// LoginHandler checks the caller's credentials and logs the account in.
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := authenticate(strings.TrimSpace(credentials.Username), credentials.Password)
+	if err == errInvalidLogin {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+
+	startAccountSession(w, r, accountID, strings.TrimSpace(credentials.Username))
+}
+
+// startAccountSession issues a new session token for the account. Any game
+// in progress on the caller's old session carries over.
+func startAccountSession(w http.ResponseWriter, r *http.Request, accountID int64, username string) {
+	if _, err := sessions.Login(w, r, accountID, username); err != nil {
+		http.Error(w, "Error creating session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": username,
+	})
+}
+
+// LogoutHandler ends the caller's session, token and all.
+func LogoutHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions.Delete(s.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
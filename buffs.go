@@ -0,0 +1,142 @@
+package main
+
+import "strings"
+
+// Ways a buff can change a stat. Additive buffs are summed onto the base
+// value first, then the result is scaled by every multiplicative buff.
+const (
+	buffMultiply = "multiply"
+	buffAdd      = "add"
+)
+
+// Stats a buff or debuff can target. "attack" scales outgoing damage.
+var buffableStats = map[string]bool{
+	"strength":     true,
+	"dexterity":    true,
+	"intelligence": true,
+	"endurance":    true,
+	"perception":   true,
+	"wisdom":       true,
+	"agility":      true,
+	"luck":         true,
+	"armor":        true,
+	"attack":       true,
+}
+
+// addBuff applies a buff, refreshing an identical one instead of stacking it.
+// Buffs that differ in stat, mode or modifier stack with each other.
+func (e *Entity) addBuff(buff Buff) {
+	if buff.Mode == "" {
+		buff.Mode = buffMultiply
+	}
+	buff.Stat = strings.ToLower(buff.Stat)
+
+	for i := range e.ActiveBuffs {
+		active := &e.ActiveBuffs[i]
+		if active.Stat == buff.Stat && active.Mode == buff.Mode && active.Modifier == buff.Modifier {
+			if buff.Duration > active.Duration {
+				active.Duration = buff.Duration
+			}
+			return
+		}
+	}
+	e.ActiveBuffs = append(e.ActiveBuffs, buff)
+}
+
+// modifyStat runs a base value through every active buff and debuff on the stat.
+func (e *Entity) modifyStat(stat string, base int) int {
+	return applyModifiers(stat, base, e.ActiveBuffs)
+}
+
+// applyModifiers runs a base value through the buffs that target stat.
+func applyModifiers(stat string, base int, buffs []Buff) int {
+	additive := 0.0
+	multiplier := 1.0
+	for _, buff := range buffs {
+		if buff.Stat != stat {
+			continue
+		}
+		if buff.Mode == buffAdd {
+			additive += buff.Modifier
+		} else {
+			multiplier *= buff.Modifier
+		}
+	}
+
+	value := int((float64(base) + additive) * multiplier)
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
+
+// tickBuffs counts down buff durations and drops the ones that have run out.
+func (e *Entity) tickBuffs() {
+	for i := 0; i < len(e.ActiveBuffs); {
+		buff := &e.ActiveBuffs[i]
+		buff.Duration--
+		if buff.Duration <= 0 {
+			e.ActiveBuffs = append(e.ActiveBuffs[:i], e.ActiveBuffs[i+1:]...)
+		} else {
+			i++
+		}
+	}
+}
+
+// Names of the character stats, in the order they are listed in Stats.
+var statNames = []string{"strength", "dexterity", "intelligence", "endurance", "perception", "wisdom", "agility", "luck"}
+
+// statPointer returns the field of stats matching name, or nil if there is none.
+func statPointer(stats *Stats, name string) *int {
+	switch strings.ToLower(name) {
+	case "strength":
+		return &stats.Strength
+	case "dexterity":
+		return &stats.Dexterity
+	case "intelligence":
+		return &stats.Intelligence
+	case "endurance":
+		return &stats.Endurance
+	case "perception":
+		return &stats.Perception
+	case "wisdom":
+		return &stats.Wisdom
+	case "agility":
+		return &stats.Agility
+	case "luck":
+		return &stats.Luck
+	}
+	return nil
+}
+
+// EffectiveStat is the character's stat after race and class traits and
+// equipment, then buffs and debuffs.
+func (c *Character) EffectiveStat(name string) int {
+	base := 0
+	if field := statPointer(&c.Stats, name); field != nil {
+		base = *field
+	}
+	base = applyModifiers(name, base, c.permanentBuffs())
+	return c.modifyStat(name, base)
+}
+
+// EffectiveStat is the enemy's stat after buffs and debuffs. Enemies only
+// define a few stats, so agility follows dexterity and perception follows
+// intelligence.
+func (e *Enemy) EffectiveStat(name string) int {
+	base := 0
+	switch name {
+	case "strength":
+		base = e.Strength
+	case "dexterity", "agility":
+		base = e.Dexterity
+	case "intelligence", "perception":
+		base = e.Intelligence
+	case "armor":
+		base = e.Armor
+	}
+	if weapon := e.weaponItem(); weapon != nil {
+		base = applyModifiers(name, base, modifierBuffs(weapon.Modifiers))
+	}
+	return e.modifyStat(name, base)
+}
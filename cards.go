@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// CardCatalog holds every card definition known to the server.
+type CardCatalog struct {
+	cards map[int]Card
+	order []int
+}
+
+var cardCatalog = &CardCatalog{cards: make(map[int]Card)}
+
+// Card rarities and how often each turns up in card rewards, relative to
+// each other. Cards without a rarity are common.
+const defaultRarity = "common"
+
+var rarityWeights = map[string]int{
+	"common":   60,
+	"uncommon": 30,
+	"rare":     10,
+}
+
+// Cards have at most one upgraded form.
+const maxCardUpgrade = 1
+
+// CardUpgrade describes a card's upgraded form. Anything left out stays as
+// it is on the base card.
+type CardUpgrade struct {
+	Name     string   `json:"name,omitempty"` // Defaults to the base name followed by "+"
+	ManaCost *int     `json:"manaCost,omitempty"`
+	Exhaust  *bool    `json:"exhaust,omitempty"`
+	Effects  []Effect `json:"effects,omitempty"` // Replace the base card's effects
+}
+
+// Parameters each effect type needs, and whether they are numeric or strings.
+var effectParameters = map[string]map[string]string{
+	"damage":         {"amount": "number"},
+	"heal":           {"amount": "number"},
+	"lifeSteal":      {"amount": "number"},
+	"damageOverTime": {"amount": "number", "duration": "number"},
+	"healOverTime":   {"amount": "number", "duration": "number"},
+	"buff":           {"stat": "string", "modifier": "number", "duration": "number"},
+	"statusEffect":   {"effect": "string", "chance": "number", "duration": "number"},
+}
+
+// loadCardCatalog reads every card file in dir and replaces the catalog
+// once all of them have been validated.
+func loadCardCatalog(dir string) error {
+	cards, err := readDataDir[Card](dir)
+	if err != nil {
+		return err
+	}
+
+	catalog := &CardCatalog{cards: make(map[int]Card)}
+	for _, card := range cards {
+		if card.Rarity == "" {
+			card.Rarity = defaultRarity
+		}
+		if err := validateCard(card); err != nil {
+			return err
+		}
+		if _, exists := catalog.cards[card.ID]; exists {
+			return fmt.Errorf("duplicate card id %d", card.ID)
+		}
+		catalog.cards[card.ID] = card
+		catalog.order = append(catalog.order, card.ID)
+	}
+	sort.Ints(catalog.order)
+
+	cardCatalog = catalog
+	return nil
+}
+
+func validateCard(card Card) error {
+	if card.ID <= 0 {
+		return fmt.Errorf("card %q has invalid id %d", card.Name, card.ID)
+	}
+	if card.Name == "" {
+		return fmt.Errorf("card %d has no name", card.ID)
+	}
+	if card.ManaCost < 0 {
+		return fmt.Errorf("card %d has negative mana cost", card.ID)
+	}
+	if _, ok := rarityWeights[card.Rarity]; !ok {
+		return fmt.Errorf("card %d has unknown rarity %q", card.ID, card.Rarity)
+	}
+	if len(card.Effects) == 0 {
+		return fmt.Errorf("card %d has no effects", card.ID)
+	}
+	for _, effect := range card.Effects {
+		if err := validateEffect(effect); err != nil {
+			return fmt.Errorf("card %d: %w", card.ID, err)
+		}
+	}
+	if upgrade := card.Upgraded; upgrade != nil {
+		if upgrade.ManaCost != nil && *upgrade.ManaCost < 0 {
+			return fmt.Errorf("card %d upgrade has negative mana cost", card.ID)
+		}
+		for _, effect := range upgrade.Effects {
+			if err := validateEffect(effect); err != nil {
+				return fmt.Errorf("card %d upgrade: %w", card.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateEffect(effect Effect) error {
+	params, ok := effectParameters[effect.Type]
+	if !ok {
+		return fmt.Errorf("unknown effect type %q", effect.Type)
+	}
+
+	switch effect.Target {
+	case "self", "player", "enemy":
+	default:
+		return fmt.Errorf("invalid target %q for effect %s", effect.Target, effect.Type)
+	}
+
+	if damageType, ok := effect.Parameters["damageType"].(string); ok && !damageTypes[damageType] {
+		return fmt.Errorf("effect %s has unknown damage type %q", effect.Type, damageType)
+	}
+
+	if effect.Type == "buff" {
+		if stat, _ := effect.Parameters["stat"].(string); !buffableStats[stat] {
+			return fmt.Errorf("effect buff targets unknown stat %q", stat)
+		}
+		switch mode, _ := effect.Parameters["mode"].(string); mode {
+		case "", buffMultiply, buffAdd:
+		default:
+			return fmt.Errorf("effect buff has unknown mode %q", mode)
+		}
+	}
+
+	for key, kind := range params {
+		switch kind {
+		case "number":
+			if _, ok := getFloatParameter(effect.Parameters, key); !ok {
+				return fmt.Errorf("effect %s needs a numeric %q parameter", effect.Type, key)
+			}
+		case "string":
+			if _, ok := effect.Parameters[key].(string); !ok {
+				return fmt.Errorf("effect %s needs a string %q parameter", effect.Type, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the card with the given ID, or nil if it doesn't exist.
+func (c *CardCatalog) Get(id int) *Card {
+	card, ok := c.cards[id]
+	if !ok {
+		return nil
+	}
+	return &card
+}
+
+// All returns every card in ID order.
+func (c *CardCatalog) All() []Card {
+	cards := make([]Card, 0, len(c.order))
+	for _, id := range c.order {
+		cards = append(cards, c.cards[id])
+	}
+	return cards
+}
+
+// upgradedTo returns the card as it plays at the given upgrade level. Cards
+// that can't be upgraded any further have no upgraded form left.
+func (card Card) upgradedTo(level int) Card {
+	if level <= 0 || card.Upgraded == nil {
+		return card
+	}
+	upgrade := card.Upgraded
+	card.Upgraded = nil
+	if upgrade.Name != "" {
+		card.Name = upgrade.Name
+	} else {
+		card.Name += "+"
+	}
+	if upgrade.ManaCost != nil {
+		card.ManaCost = *upgrade.ManaCost
+	}
+	if upgrade.Exhaust != nil {
+		card.Exhaust = *upgrade.Exhaust
+	}
+	if len(upgrade.Effects) > 0 {
+		card.Effects = upgrade.Effects
+	}
+	return card
+}
+
+// drawByRarity picks up to count distinct entries from pool, each weighted
+// by its rarity. The pool itself is left untouched.
+func drawByRarity[T any](pool []T, count int, weights map[string]int, rarity func(T) string) []T {
+	pool = append([]T(nil), pool...)
+
+	var drawn []T
+	for len(drawn) < count && len(pool) > 0 {
+		total := 0
+		for _, entry := range pool {
+			total += weights[rarity(entry)]
+		}
+		if total <= 0 {
+			break
+		}
+
+		i := 0
+		for roll := rand.Intn(total); i < len(pool)-1; i++ {
+			roll -= weights[rarity(pool[i])]
+			if roll < 0 {
+				break
+			}
+		}
+		drawn = append(drawn, pool[i])
+		pool = append(pool[:i], pool[i+1:]...)
+	}
+	return drawn
+}
+
+func cardRarity(card Card) string {
+	return card.Rarity
+}
+
+// cardIDs lists the IDs of the given cards.
+func cardIDs(cards []Card) []int {
+	ids := []int{}
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+	}
+	return ids
+}
+
+// Pool returns the cards a character of the given class can use: its own
+// class cards plus every neutral card.
+func (c *CardCatalog) Pool(class string) []Card {
+	var pool []Card
+	for _, id := range c.order {
+		if card := c.cards[id]; card.usableBy(class) {
+			pool = append(pool, card)
+		}
+	}
+	return pool
+}
+
+// usableBy reports whether a character of the given class may put the card in its deck.
+func (card Card) usableBy(class string) bool {
+	return card.Class == "" || card.Class == class
+}
+
+func getCardByID(cardID int) *Card {
+	return cardCatalog.Get(cardID)
+}
+
+func CardsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cardCatalog.All())
+}
+
+func CardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid card id", http.StatusBadRequest)
+		return
+	}
+
+	card := getCardByID(id)
+	if card == nil {
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
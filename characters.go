@@ -0,0 +1,337 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// A character's latest save is spread over relational tables so it can be
+// queried: the character row itself, its stats, the card instances in its
+// deck, its gear and its progress through the current run. Offers that are
+// only ever read back whole (level-up picks, stat boosts, rewards and the
+// merchant's stock) are kept as JSON on the character row. Combat effects
+// aren't stored; saves only happen between fights.
+
+// Tables that hang off a character and are rewritten on every save.
+var characterTables = []string{
+	"character_stats",
+	"character_cards",
+	"character_equipment",
+	"character_inventory",
+	"run_progress",
+	"run_nodes",
+	"run_paths",
+}
+
+// pendingState is the part of a character kept as JSON.
+type pendingState struct {
+	CardPicks   [][]int          `json:"cardPicks,omitempty"`
+	BoostOffers []StatBoostOffer `json:"boostOffers,omitempty"`
+	Rewards     []Reward         `json:"rewards,omitempty"`
+	Shop        *Shop            `json:"shop,omitempty"`
+}
+
+// CharacterRepository stores and loads accounts' characters and their save
+// history (see saves.go). Each save or load runs in a single transaction, so
+// a failed save leaves the previous one whole and a load never sees half of
+// a save.
+type CharacterRepository struct {
+	db *sql.DB
+}
+
+var characterRepo *CharacterRepository
+
+var errCharacterExists = errors.New("character already exists")
+
+// Create writes a new character for the account. It returns
+// errCharacterExists rather than overwrite one with the same name.
+func (repo *CharacterRepository) Create(accountID int64, p Character) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = findCharacter(tx, accountID, p.Name)
+	if err == nil {
+		return errCharacterExists
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	if _, err := saveCharacter(tx, accountID, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Load reads the account's character with the given name. It returns
+// sql.ErrNoRows if the account has no such character.
+func (repo *CharacterRepository) Load(accountID int64, name string) (Character, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return Character{}, err
+	}
+	defer tx.Rollback()
+
+	id, err := findCharacter(tx, accountID, name)
+	if err != nil {
+		return Character{}, err
+	}
+	return loadCharacter(tx, id)
+}
+
+// findCharacter returns the ID of the account's character with the given name.
+func findCharacter(tx *sql.Tx, accountID int64, name string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM characters WHERE account_id = ? AND name = ?;`, accountID, name).Scan(&id)
+	return id, err
+}
+
+// saveCharacter writes the character's rows within tx and returns its ID.
+func saveCharacter(tx *sql.Tx, accountID int64, p Character) (int64, error) {
+	pending, err := json.Marshal(pendingState{
+		CardPicks:   p.CardPicks,
+		BoostOffers: p.BoostOffers,
+		Rewards:     p.Rewards,
+		Shop:        p.Shop,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+    INSERT INTO characters (account_id, name, class, race, level, xp, health, max_health, mana, max_mana, gold, stat_points, card_removals, pending)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    ON CONFLICT(account_id, name) DO UPDATE SET
+        class=excluded.class, race=excluded.race, level=excluded.level, xp=excluded.xp,
+        health=excluded.health, max_health=excluded.max_health, mana=excluded.mana, max_mana=excluded.max_mana,
+        gold=excluded.gold, stat_points=excluded.stat_points, card_removals=excluded.card_removals, pending=excluded.pending;
+    `, accountID, p.Name, p.Class, p.Race, p.Level, p.XP, p.Health, p.MaxHealth, p.Mana, p.MaxMana, p.Gold, p.StatPoints, p.CardRemovals, string(pending))
+	if err != nil {
+		return 0, err
+	}
+	id, err := findCharacter(tx, accountID, p.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, table := range characterTables {
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE character_id = ?;`, table), id); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, stat := range statNames {
+		_, err := tx.Exec(`INSERT INTO character_stats (character_id, stat, value) VALUES (?, ?, ?);`, id, stat, *statPointer(&p.Stats, stat))
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i, card := range p.Deck {
+		_, err := tx.Exec(`INSERT INTO character_cards (character_id, position, instance_id, card_id, upgrade) VALUES (?, ?, ?, ?, ?);`,
+			id, i, card.ID, card.CardID, card.Upgrade)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for slot, itemID := range p.Equipment {
+		_, err := tx.Exec(`INSERT INTO character_equipment (character_id, slot, item_id) VALUES (?, ?, ?);`, id, slot, itemID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i, itemID := range p.Inventory {
+		_, err := tx.Exec(`INSERT INTO character_inventory (character_id, position, item_id) VALUES (?, ?, ?);`, id, i, itemID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if p.Run != nil {
+		if err := saveRun(tx, id, p.Run); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func saveRun(tx *sql.Tx, characterID int64, run *Run) error {
+	_, err := tx.Exec(`INSERT INTO run_progress (character_id, act, status, position) VALUES (?, ?, ?, ?);`,
+		characterID, run.Act, run.Status, run.Position)
+	if err != nil {
+		return err
+	}
+	for _, node := range run.Nodes {
+		_, err := tx.Exec(`
+        INSERT INTO run_nodes (character_id, node_id, floor, lane, type, visited, cleared, outcome)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+        `, characterID, node.ID, node.Floor, node.Lane, node.Type, node.Visited, node.Cleared, node.Outcome)
+		if err != nil {
+			return err
+		}
+		for i, next := range node.Next {
+			_, err := tx.Exec(`INSERT INTO run_paths (character_id, from_node, position, to_node) VALUES (?, ?, ?, ?);`,
+				characterID, node.ID, i, next)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadCharacter reads the character with the given ID within tx.
+func loadCharacter(tx *sql.Tx, id int64) (Character, error) {
+	var p Character
+	var pendingJSON string
+	err := tx.QueryRow(`
+    SELECT name, class, race, level, xp, health, max_health, mana, max_mana, gold, stat_points, card_removals, pending
+    FROM characters WHERE id = ?;
+    `, id).Scan(&p.Name, &p.Class, &p.Race, &p.Level, &p.XP, &p.Health, &p.MaxHealth, &p.Mana, &p.MaxMana, &p.Gold, &p.StatPoints, &p.CardRemovals, &pendingJSON)
+	if err != nil {
+		return p, err
+	}
+	var pending pendingState
+	if err := json.Unmarshal([]byte(pendingJSON), &pending); err != nil {
+		return p, err
+	}
+	p.CardPicks = pending.CardPicks
+	p.BoostOffers = pending.BoostOffers
+	p.Rewards = pending.Rewards
+	p.Shop = pending.Shop
+
+	rows, err := tx.Query(`SELECT stat, value FROM character_stats WHERE character_id = ?;`, id)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var stat string
+		var value int
+		if err := rows.Scan(&stat, &value); err != nil {
+			rows.Close()
+			return p, err
+		}
+		if field := statPointer(&p.Stats, stat); field != nil {
+			*field = value
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return p, err
+	}
+
+	p.Deck = []CardInstance{}
+	rows, err = tx.Query(`SELECT instance_id, card_id, upgrade FROM character_cards WHERE character_id = ? ORDER BY position;`, id)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var card CardInstance
+		if err := rows.Scan(&card.ID, &card.CardID, &card.Upgrade); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p.Deck = append(p.Deck, card)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return p, err
+	}
+
+	p.Equipment = make(map[string]string)
+	rows, err = tx.Query(`SELECT slot, item_id FROM character_equipment WHERE character_id = ?;`, id)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var slot, itemID string
+		if err := rows.Scan(&slot, &itemID); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p.Equipment[slot] = itemID
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return p, err
+	}
+
+	p.Inventory = []string{}
+	rows, err = tx.Query(`SELECT item_id FROM character_inventory WHERE character_id = ? ORDER BY position;`, id)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var itemID string
+		if err := rows.Scan(&itemID); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p.Inventory = append(p.Inventory, itemID)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return p, err
+	}
+
+	p.Run, err = loadRun(tx, id)
+	return p, err
+}
+
+// loadRun reads the character's run, or nil if it has none.
+func loadRun(tx *sql.Tx, characterID int64) (*Run, error) {
+	run := &Run{Nodes: []MapNode{}}
+	err := tx.QueryRow(`SELECT act, status, position FROM run_progress WHERE character_id = ?;`, characterID).
+		Scan(&run.Act, &run.Status, &run.Position)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+    SELECT node_id, floor, lane, type, visited, cleared, outcome
+    FROM run_nodes WHERE character_id = ? ORDER BY node_id;
+    `, characterID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		node := MapNode{Next: []int{}}
+		if err := rows.Scan(&node.ID, &node.Floor, &node.Lane, &node.Type, &node.Visited, &node.Cleared, &node.Outcome); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		run.Nodes = append(run.Nodes, node)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT from_node, to_node FROM run_paths WHERE character_id = ? ORDER BY from_node, position;`, characterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from, to int
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		// Node IDs are their index in the act
+		if from >= 0 && from < len(run.Nodes) {
+			run.Nodes[from].Next = append(run.Nodes[from].Next, to)
+		}
+	}
+	return run, rows.Err()
+}
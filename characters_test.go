@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSavesAndLoads(t *testing.T) {
+	testDB, err := openDatabase(filepath.Join(t.TempDir(), "game.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+	if err := migrate(testDB); err != nil {
+		t.Fatal(err)
+	}
+	repo := &CharacterRepository{db: testDB}
+
+	const accounts, rounds = 20, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, accounts*rounds*2)
+	for account := 1; account <= accounts; account++ {
+		wg.Add(1)
+		go func(accountID int64) {
+			defer wg.Done()
+			p := version1Character()
+			p.Name = fmt.Sprintf("Hero %d", accountID)
+			for round := 0; round < rounds; round++ {
+				p.Gold = round
+				slot := checkpointSlot
+				if round%2 == 0 {
+					slot = 1
+				}
+				if err := repo.Save(accountID, p, slot, "Test"); err != nil {
+					errs <- fmt.Errorf("account %d save: %w", accountID, err)
+					continue
+				}
+				loaded, err := repo.Load(accountID, p.Name)
+				if err != nil {
+					errs <- fmt.Errorf("account %d load: %w", accountID, err)
+					continue
+				}
+				if loaded.Name != p.Name {
+					errs <- fmt.Errorf("account %d loaded %q", accountID, loaded.Name)
+				}
+			}
+		}(int64(account))
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		failed++
+		if failed <= 5 {
+			t.Error(err)
+		}
+	}
+	if failed > 0 {
+		t.Errorf("%d of %d operations failed", failed, accounts*rounds*2)
+	}
+}
@@ -1,21 +1,24 @@
 $(document).ready(function () {
+  // Version of the combat response schema this client understands (GET /schema/combat)
+  const COMBAT_API_VERSION = 2;
+
   //#region Tooltip
   let tooltipTimeout;
 
-  $(".info-icon").hover(
-    function (e) {
+  // Delegated so icons rendered from server data get tooltips too
+  $(document)
+    .on("mouseenter", ".info-icon", function (e) {
       const $tooltip = $(this);
       clearTimeout(tooltipTimeout);
 
       tooltipTimeout = setTimeout(function () {
         showTooltip($tooltip, e);
       }, 300);
-    },
-    function () {
+    })
+    .on("mouseleave", ".info-icon", function () {
       clearTimeout(tooltipTimeout);
       hideTooltip($(this));
-    }
-  );
+    });
 
   function showTooltip($icon, event) {
     const tooltipText = $icon.attr("data-info");
@@ -69,18 +72,116 @@ $(document).ready(function () {
 
   //#endregion Loading
 
+  //#region Session
+  // Each tab keeps its own session token so playtesters don't share state.
+  $.ajaxSetup({
+    beforeSend: function (xhr) {
+      const token = sessionStorage.getItem("sessionToken");
+      if (token) {
+        xhr.setRequestHeader("X-Session-Token", token);
+      }
+    },
+  });
+
+  $(document).ajaxComplete(function (event, xhr) {
+    const token = xhr.getResponseHeader("X-Session-Token");
+    if (token) {
+      sessionStorage.setItem("sessionToken", token);
+    }
+  });
+  //#endregion Session
+
+  //#region Account
+  function showAccount(username) {
+    $("#account-name").text(username);
+    $("#login-form").toggle(!username);
+    $("#logged-in").toggle(!!username);
+    $("#password").val("");
+  }
+
+  function authenticate(url) {
+    $.ajax({
+      url: url,
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({
+        username: $("#username").val(),
+        password: $("#password").val(),
+      }),
+      success: function (response) {
+        showAccount(response.username);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText || "Couldn't log in.");
+      },
+    });
+  }
+
+  $("#login-form").submit(function (e) {
+    e.preventDefault();
+    authenticate("http://localhost:8080/login");
+  });
+
+  $("#register-btn").click(function () {
+    authenticate("http://localhost:8080/register");
+  });
+
+  $("#logout-btn").click(function () {
+    $.ajax({
+      url: "http://localhost:8080/logout",
+      type: "POST",
+      complete: function () {
+        // The token is dead either way; start over with a fresh session
+        sessionStorage.removeItem("sessionToken");
+        location.reload();
+      },
+    });
+  });
+  //#endregion Account
+
   //#region Character Creation
   let playerData = null;
   let selectedRace = null;
   let selectedClass = null;
 
-  $(".raceb").click(function () {
+  // Races and classes are defined on the server
+  function renderChoices(url, $list, itemClass, dataKey) {
+    $.ajax({
+      url: url,
+      type: "GET",
+      success: function (choices) {
+        $list.empty();
+        choices.forEach((choice) => {
+          const traits = (choice.traits || [])
+            .map((trait) => `${trait.name}: ${trait.description}`)
+            .join(" ");
+          const $info = $('<i class="fas fa-question-circle info-icon"></i>').attr(
+            "data-info",
+            `${choice.description} ${traits}`.trim()
+          );
+          $("<div>")
+            .addClass(itemClass)
+            .attr(`data-${dataKey}`, choice.name)
+            .text(choice.name + " ")
+            .append($info)
+            .appendTo($list);
+        });
+      },
+      error: function (xhr, status, error) {
+        console.error(`Error loading ${url}:`, status, error);
+      },
+    });
+  }
+  renderChoices("http://localhost:8080/races", $(".race-list"), "raceb", "race");
+  renderChoices("http://localhost:8080/classes", $(".class-list"), "classb", "class");
+
+  $(document).on("click", ".raceb", function () {
     $(".raceb").removeClass("highlight");
     $(this).addClass("highlight");
     selectedRace = $(this).data("race");
   });
 
-  $(".classb").click(function () {
+  $(document).on("click", ".classb", function () {
     $(".classb").removeClass("highlight");
     $(this).addClass("highlight");
     selectedClass = $(this).data("class");
@@ -107,17 +208,19 @@ $(document).ready(function () {
       success: function (character) {
         if (character && character.stats) {
           displayCharacterInfo(character);
-          $("#card-selection").show();
+          setPlayerDeck(character.deck);
           $("#character-creation").remove();
           $("#character-overview").show();
           $("#toggle-overview-btn").show();
           $("#start-combat-btn").show();
+          loadMap();
         } else {
           console.error("Character stats missing from server response.");
         }
       },
       error: function (xhr, status, error) {
         console.error("Error creating character:", status, error);
+        alert(xhr.responseText);
       },
     });
   });
@@ -131,32 +234,50 @@ $(document).ready(function () {
 
     // Update button text based on visibility
     if ($("#deck-builder").is(":visible")) {
-      $(this).text("Hide Deck Builder");
+      $(this).text("Hide Deck");
     } else {
-      $(this).text("Show Deck Builder");
+      $(this).text("Show Deck");
     }
   });
 
-  function generateBuildDeck() {
-    const cards = [
-      { id: 1, name: "Fireball", damage: 10, manaCost: 5 },
-      { id: 2, name: "Ice Shard", damage: 8, manaCost: 4 },
-      { id: 3, name: "Healing Light", heal: 15, manaCost: 6 },
-      { id: 4, name: "Shadow Strike", damage: 12, manaCost: 7 },
-    ];
+  // Card definitions come from the server's catalog
+  let cardCatalog = [];
+
+  function loadCardCatalog() {
+    $.ajax({
+      url: "http://localhost:8080/cards",
+      type: "GET",
+      success: function (cards) {
+        cardCatalog = cards;
+        generateBuildDeck();
+        renderUpgradeChoices();
+      },
+      error: function (xhr, status, error) {
+        console.error("Error loading cards:", status, error);
+      },
+    });
+  }
 
+  function renderCardEffects(card) {
+    return card.effects
+      .map((effect) => `<p>${effect.description}</p>`)
+      .join("");
+  }
+
+  // Cards are earned and removed elsewhere; this only shows what's in the deck
+  function generateBuildDeck() {
     $("#deck-builder").empty();
-    cards.forEach((card) => {
+    cardCatalog
+      .filter((card) => playerDeck.includes(card.id))
+      .forEach((card) => {
+      const copies = playerDeck.filter((id) => id === card.id).length;
       const cardElement = $(`
-                <div class="card" data-id="${card.id}">
+                <div class="card rarity-${card.rarity}" data-id="${card.id}">
                     <h3>${card.name}</h3>
-                    <p>${
-                      card.damage
-                        ? `Damage: ${card.damage}`
-                        : `Heal: ${card.heal}`
-                    }</p>
+                    ${renderCardEffects(card)}
                     <p>Mana Cost: ${card.manaCost}</p>
-                    <button class="add-to-deck">Add to Deck</button>
+                    <p>${card.class || "Neutral"} &middot; ${card.rarity}</p>
+                    <p>In Deck: ${copies}</p>
                 </div>
             `);
 
@@ -164,21 +285,44 @@ $(document).ready(function () {
     });
   }
 
-  $(document).on("click", ".add-to-deck", function () {
-    const cardId = $(this).closest(".card").data("id");
-    const cardName = $(this).siblings("h3").text();
+  // Every copy in the deck, with its instance ID and upgrade level
+  let deckInstances = [];
 
-    if (playerDeck.includes(cardId)) {
-      playerDeck = playerDeck.filter((id) => id !== cardId);
-      $(this).text("Add to Deck");
-      alert(`${cardName} removed from deck`);
-    } else {
-      playerDeck.push(cardId);
-      $(this).text("Remove from Deck");
-      alert(`${cardName} added to deck`);
+  // The server owns the deck list; show whatever it accepted
+  function setPlayerDeck(deck) {
+    deckInstances = deck || [];
+    playerDeck = deckInstances.map((instance) => instance.cardId);
+    generateBuildDeck();
+    renderUpgradeChoices();
+  }
+
+  function instanceName(instance) {
+    const card = getCardById(instance.cardId);
+    if (!card) {
+      return `Card ${instance.cardId}`;
     }
-  });
-  generateBuildDeck();
+    if (instance.upgrade > 0) {
+      return card.upgraded && card.upgraded.name ? card.upgraded.name : `${card.name}+`;
+    }
+    return card.name;
+  }
+
+  function canUpgrade(instance) {
+    const card = getCardById(instance.cardId);
+    return !!card && !!card.upgraded && instance.upgrade < 1;
+  }
+
+  // Fill every "upgrade a card" picker with the copies that can still be upgraded
+  function renderUpgradeChoices() {
+    $(".upgrade-card-select").each(function () {
+      const $select = $(this).empty();
+      deckInstances.filter(canUpgrade).forEach((instance) => {
+        $("<option>").val(instance.id).text(instanceName(instance)).appendTo($select);
+      });
+    });
+  }
+
+  loadCardCatalog();
   //#endregion Deck Building
 
   //#region Combat
@@ -195,6 +339,9 @@ $(document).ready(function () {
     $("#combat-controls").show();
   });
 
+  // Cards currently in hand as the server deals and resolves them
+  let combatHand = [];
+
   // Show cards for selection when "Select Card" is clicked
   $("#select-card-btn").click(function () {
     $("#combat-hand").show();
@@ -203,16 +350,11 @@ $(document).ready(function () {
 
   function generateCombatHand() {
     $("#combat-cards").empty();
-    playerDeck.forEach((cardId) => {
-      const card = getCardById(cardId);
+    combatHand.forEach((card) => {
       const cardElement = $(`
-                <div class="card" data-id="${card.id}">
+                <div class="card" data-id="${card.instanceId}">
                     <h3>${card.name}</h3>
-                    <p>${
-                      card.damage
-                        ? `Damage: ${card.damage}`
-                        : `Heal: ${card.heal}`
-                    }</p>
+                    ${renderCardEffects(card)}
                     <p>Mana Cost: ${card.manaCost}</p>
                 </div>
             `);
@@ -239,15 +381,15 @@ $(document).ready(function () {
     }
   });
 
-  function executeCombatRound(action, cardId = null) {
+  function executeCombatRound(action, instanceId = null) {
     if (!combatInProgress) {
       alert("Combat has ended.");
       return;
     }
 
     const requestData = { action: action };
-    if (cardId) {
-      requestData.cardId = cardId;
+    if (instanceId) {
+      requestData.instanceId = instanceId;
     }
 
    $.ajax({
@@ -256,6 +398,14 @@ $(document).ready(function () {
     contentType: "application/json",
     data: JSON.stringify(requestData),
     success: function (response) {
+       if (response.version !== COMBAT_API_VERSION) {
+         console.warn("Combat API version " + response.version + " differs from client version " + COMBAT_API_VERSION);
+       }
+       combatHand = response.hand || [];
+       if ($("#combat-hand").is(":visible")) {
+         generateCombatHand();
+       }
+       updateEnemyIntent(response.enemyIntent);
        if(response.result == "fight started")
         {
            $("#combat-info").show(); 
@@ -281,6 +431,7 @@ $(document).ready(function () {
         else
         {
 updateCombatLog(response.result);
+        updateDamageLog(response.events);
         updateBars(
             response.playerHP, 
             response.playerMaxHP, 
@@ -291,17 +442,8 @@ updateCombatLog(response.result);
         );
         updateBarStyles();
         
-        // Calculate and display floating text for player health changes
-        if (response.playerHP !== playerData.health) {
-            const deltaHP = response.playerHP - playerData.health;
-            const playerBarOffset = $('#player-hp-bar').offset();
-            showFloatingText(
-                deltaHP > 0 ? `+${deltaHP}` : `${deltaHP}`,
-                playerBarOffset.left + 50, // Adjust offset for proper positioning
-                playerBarOffset.top - 10,
-                deltaHP > 0 ? 'green' : 'red'
-            );
-        }
+        // Float a number for every hit and heal, in the order they happened
+        showEventNumbers(response.events);
 
         // Calculate and display floating text for player mana changes
         if (response.playerMana !== playerData.mana) {
@@ -315,18 +457,6 @@ updateCombatLog(response.result);
             );
         }
 
-        // Calculate and display floating text for enemy health changes
-        if (response.enemyHP !== currentEnemy.health) {
-            const deltaEnemyHP = response.enemyHP - currentEnemy.health;
-            const enemyBarOffset = $('#enemy-hp-bar').offset();
-            showFloatingText(
-                deltaEnemyHP > 0 ? `+${deltaEnemyHP}` : `${deltaEnemyHP}`,
-                enemyBarOffset.left + 50, // Adjust offset for proper positioning
-                enemyBarOffset.top - 10,
-                deltaEnemyHP > 0 ? 'green' : 'red'
-            );
-        }
-
         if (response.combatOver) {
           combatInProgress = false;
           $("#attack-btn, #use-card-btn, #select-card-btn").prop(
@@ -335,6 +465,9 @@ updateCombatLog(response.result);
           );
           $("#combat-hand").hide();
           $("#combat-info").hide();
+          refreshCharacter();
+          loadRewards();
+          loadMap();
           alert("Combat has ended!");
         }
         else
@@ -360,14 +493,29 @@ updateCombatLog(response.result);
     },
     error: function (xhr, status, error) {
         console.error("Error during combat round:", status, error);
-        alert("Something went wrong during the combat round.");
+        combatInProgress = false;
+        alert(xhr.responseText || "Something went wrong during the combat round.");
     }
 });
  
   }
 
+  // Show what the enemy is planning to do on its next turn
+  function updateEnemyIntent(intent) {
+    if (!intent) {
+      $("#enemy-intent").text("");
+      return;
+    }
+    let text = `Intent: ${intent.ability} (${intent.type})`;
+    if (intent.damage) {
+      text += ` - ${intent.damage} damage`;
+    }
+    $("#enemy-intent").text(text).attr("title", intent.description);
+  }
+
   function getSelectedCardId() {
-    return $("#combat-cards .card.selected").data("id");
+    // Instance IDs are hex strings, so read the raw attribute rather than .data()
+    return $("#combat-cards .card.selected").attr("data-id");
   }
 
   function updateCombatLog(message) {
@@ -375,6 +523,50 @@ updateCombatLog(response.result);
     $("#combat-log").append(logEntry);
   }
 
+  // Break each hit down into its rolls and mitigation
+  function updateDamageLog(events) {
+    (events || [])
+      .filter((event) => event.hit)
+      .forEach((event) => {
+        const hit = event.hit;
+        let text = hit.dodged
+          ? `${hit.defender} dodged ${hit.attacker}'s attack`
+          : `${hit.attacker} hit ${hit.defender} for ${hit.final} ${hit.damageType} (${hit.base} base`;
+        if (!hit.dodged) {
+          if (hit.critical) {
+            text += ", critical";
+          }
+          text += `, ${hit.mitigated} mitigated)`;
+        }
+        $("#combat-log").append($('<p class="damage-detail"></p>').text(text));
+      });
+  }
+
+  function showEventNumbers(events) {
+    const healthEvents = {
+      damageDealt: -1,
+      dotTicked: -1,
+      healed: 1,
+      hotTicked: 1,
+    };
+    (events || [])
+      .filter((event) => healthEvents[event.type] && event.amount)
+      .forEach((event, index) => {
+        const bar =
+          event.target === playerData.name ? "#player-hp-bar" : "#enemy-hp-bar";
+        const offset = $(bar).offset();
+        const sign = healthEvents[event.type];
+        setTimeout(function () {
+          showFloatingText(
+            sign > 0 ? `+${event.amount}` : `-${event.amount}`,
+            offset.left + 50, // Adjust offset for proper positioning
+            offset.top - 10,
+            sign > 0 ? "green" : "red"
+          );
+        }, index * 300);
+      });
+  }
+
   function updateHPDisplay(playerHP, enemyHP) {
     $("#player-hp").text(playerHP);
     $("#enemy-hp").text(enemyHP);
@@ -429,38 +621,33 @@ updateCombatLog(response.result);
     }
 }
   function getCardById(id) {
-    const cards = [
-      { id: 1, name: "Fireball", damage: 10, manaCost: 5 },
-      { id: 2, name: "Ice Shard", damage: 8, manaCost: 4 },
-      { id: 3, name: "Healing Light", heal: 15, manaCost: 6 },
-      { id: 4, name: "Shadow Strike", damage: 12, manaCost: 7 },
-    ];
-    return cards.find((card) => card.id === id);
+    return cardCatalog.find((card) => card.id === id);
   }
   //#endregion Combat
 
   //#region Save/Load Progress
   $("#save-progress-btn").click(function () {
     if (playerData) {
-      saveProgress(playerData);
+      saveProgress();
     } else {
       alert("No player data available to save.");
     }
   });
 
-  function saveProgress(playerData) {
+  // The server saves its own copy of the character; only the slot is sent
+  function saveProgress() {
     $.ajax({
       url: "http://localhost:8080/save-progress",
       type: "POST",
       contentType: "application/json",
-      data: JSON.stringify(playerData),
+      data: JSON.stringify({ slot: parseInt($("#save-slot").val(), 10) }),
       success: function (response) {
         alert("Progress saved successfully!");
         console.log(response);
       },
       error: function (xhr, status, error) {
         console.error("Error saving progress:", status, error);
-        alert("Error saving progress.");
+        alert(xhr.responseText || "Error saving progress.");
       },
     });
   }
@@ -480,19 +667,88 @@ updateCombatLog(response.result);
       data: JSON.stringify({ name: playerName }),
       success: function (loadedPlayerData) {
         playerData = loadedPlayerData;
+        setPlayerDeck(playerData.deck);
         $("#character-creation").remove();
         $("#character-overview").show();
         $("#toggle-overview-btn").show();
         $("#start-combat-btn").show();
         displayCharacterInfo(playerData);
+        loadMap();
         alert("Progress loaded successfully!");
       },
       error: function (xhr, status, error) {
         console.error("Error loading progress:", status, error);
-        alert("Error loading progress.");
+        alert(xhr.responseText || "Error loading progress.");
+      },
+    });
+  }
+
+  $("#show-saves-btn").click(function () {
+    if ($("#save-history").is(":visible")) {
+      $("#save-history").hide();
+      return;
+    }
+    loadSaveHistory();
+  });
+
+  function loadSaveHistory() {
+    $.ajax({
+      url: "http://localhost:8080/saves",
+      type: "GET",
+      success: function (response) {
+        renderSaveHistory(response.saves);
       },
+      error: function (xhr) {
+        alert(xhr.responseText || "Error loading saves.");
+      },
+    });
+  }
+
+  function renderSaveHistory(saves) {
+    const $history = $("#save-history").empty().show();
+    if (saves.length === 0) {
+      $history.append("<p>No saves yet.</p>");
+      return;
+    }
+    saves.forEach(function (save) {
+      const where = save.floor > 0 ? `Act ${save.act}, floor ${save.floor}` : `Act ${save.act}, start`;
+      $("<div>")
+        .addClass("save-entry")
+        .text(
+          `${save.label} - ${new Date(save.savedAt).toLocaleString()} - Level ${save.level}, ${save.gold} gold, ${where} `
+        )
+        .append(
+          $("<button>")
+            .addClass("restore-save-btn")
+            .attr("data-id", save.id)
+            .text("Restore")
+        )
+        .appendTo($history);
     });
   }
+
+  $("#save-history").on("click", ".restore-save-btn", function () {
+    if (!confirm("Restore this save? Unsaved progress will be lost.")) {
+      return;
+    }
+    $.ajax({
+      url: "http://localhost:8080/saves/restore",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ saveId: parseInt($(this).attr("data-id"), 10) }),
+      success: function (restoredPlayer) {
+        playerData = restoredPlayer;
+        setPlayerDeck(playerData.deck);
+        $("#combat-controls").hide();
+        displayCharacterInfo(playerData);
+        loadMap();
+        $("#save-history").hide();
+      },
+      error: function (xhr) {
+        alert(xhr.responseText || "Error restoring save.");
+      },
+    });
+  });
   //#endregion Save/Load Progress
 
   //#region Character Info Display
@@ -528,10 +784,463 @@ updateCombatLog(response.result);
     $("#health-display").text(`${character.health} / ${character.maxHealth}`);
     $("#mana-display").text(`${character.mana} / ${character.maxMana}`);
 
-    $("#armor-display").text(character.armor);
-    $("#weapon-display").text(character.weapon);
+    renderEquipment(character);
 
     $("#gold-display").text(character.gold);
+    renderBoostOffers(character.boostOffers || []);
+    renderRewards(character.rewards || []);
+    refreshProgression();
+  }
+
+  function refreshCharacter() {
+    $.ajax({
+      url: "http://localhost:8080/character",
+      type: "GET",
+      success: function (character) {
+        displayCharacterInfo(character);
+        setPlayerDeck(character.deck);
+      },
+    });
   }
   //#endregion Character Info Display
+
+  //#region Inventory
+  // Item definitions come from the server's catalog
+  let itemCatalog = {};
+
+  $.ajax({
+    url: "http://localhost:8080/items",
+    type: "GET",
+    success: function (items) {
+      items.forEach((item) => (itemCatalog[item.id] = item));
+      if (playerData) {
+        renderEquipment(playerData);
+      }
+    },
+    error: function (xhr, status, error) {
+      console.error("Error loading items:", status, error);
+    },
+  });
+
+  function itemName(id) {
+    return itemCatalog[id] ? itemCatalog[id].name : id;
+  }
+
+  function renderEquipment(character) {
+    const equipment = character.equipment || {};
+    ["armor", "weapon", "trinket"].forEach((slot) => {
+      $(`#${slot}-display`).text(equipment[slot] ? itemName(equipment[slot]) : "None");
+      $(`.unequip-btn[data-slot="${slot}"]`).toggle(!!equipment[slot]);
+    });
+
+    const $items = $("#inventory-items").empty();
+    (character.inventory || []).forEach((id) => {
+      const item = itemCatalog[id];
+      $("<div>")
+        .addClass("inventory-item")
+        .attr("title", item ? item.description : "")
+        .text(`${itemName(id)} (${item ? item.slot : "?"}) `)
+        .append($("<button>").text("Equip").click(() => changeEquipment("equip", { itemId: id })))
+        .appendTo($items);
+    });
+    if ($items.is(":empty")) {
+      $items.text("Empty");
+    }
+  }
+
+  $(".unequip-btn").click(function () {
+    changeEquipment("unequip", { slot: $(this).data("slot") });
+  });
+
+  function changeEquipment(action, data) {
+    $.ajax({
+      url: `http://localhost:8080/${action}`,
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify(data),
+      success: function () {
+        refreshCharacter();
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+  //#endregion Inventory
+
+  //#region Shop
+  $("#toggle-shop-btn").click(function () {
+    $("#shop").toggle();
+    if ($("#shop").is(":visible")) {
+      $(this).text("Leave Shop");
+      loadShop();
+    } else {
+      $(this).text("Visit Shop");
+    }
+  });
+
+  function loadShop() {
+    $.ajax({
+      url: "http://localhost:8080/shop",
+      type: "GET",
+      success: function (response) {
+        renderShop(response.shop);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+
+  function renderShop(shop) {
+    const $offers = $("#shop-offers").empty();
+    shop.offers.forEach((offer) => {
+      let name;
+      if (offer.type === "card") {
+        const card = getCardById(offer.cardId);
+        name = card ? `${card.name} (${card.rarity} card)` : `Card ${offer.cardId}`;
+      } else {
+        name = `${itemName(offer.itemId)} (item)`;
+      }
+      $("<div>")
+        .addClass("shop-offer")
+        .text(`${name} - ${offer.price} gold `)
+        .append(
+          $("<button>")
+            .text(offer.sold ? "Sold" : "Buy")
+            .prop("disabled", offer.sold)
+            .click(() => shopAction("buy", { offerId: offer.id }))
+        )
+        .appendTo($offers);
+    });
+
+    $("#removal-price").text(shop.removalPrice);
+    const $select = $("#removal-card").empty();
+    deckInstances.forEach((instance) => {
+      $("<option>").val(instance.id).text(instanceName(instance)).appendTo($select);
+    });
+    $("#remove-card-btn").prop("disabled", shop.removalUsed);
+    $("#upgrade-price").text(shop.upgradePrice);
+  }
+
+  $("#remove-card-btn").click(function () {
+    shopAction("remove-card", { instanceId: $("#removal-card").val() });
+  });
+
+  $("#shop-upgrade-btn").click(function () {
+    shopAction("upgrade", { instanceId: $("#shop-upgrade-card").val() });
+  });
+
+  function shopAction(action, data) {
+    $.ajax({
+      url: `http://localhost:8080/shop/${action}`,
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify(data),
+      success: function (character) {
+        displayCharacterInfo(character);
+        setPlayerDeck(character.deck);
+        renderShop(character.shop);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+  //#endregion Shop
+
+  //#region Dungeon Map
+  const nodeLabels = {
+    combat: "Fight",
+    elite: "Elite",
+    rest: "Rest",
+    shop: "Shop",
+    event: "Event",
+    boss: "Boss",
+  };
+
+  function loadMap() {
+    $.ajax({
+      url: "http://localhost:8080/map",
+      type: "GET",
+      success: function (response) {
+        renderMap(response.run, response.choices);
+      },
+    });
+  }
+
+  function renderMap(run, choices) {
+    $("#dungeon-map-section").show();
+    $("#map-act").text(run.act);
+    $("#new-run-btn").toggle(run.status !== "active");
+    const current = run.nodes[run.position];
+    if (run.status === "defeated") {
+      $("#map-status").text("You have fallen. Your run is over.");
+    } else if (run.status === "won") {
+      $("#map-status").text("You have conquered the dungeon!");
+    } else {
+      $("#map-status").text(current && current.outcome ? current.outcome : "");
+    }
+    $("#rest-site").toggle(!!current && current.type === "rest" && !current.cleared);
+
+    const $map = $("#dungeon-map").empty();
+    const floors = [];
+    run.nodes.forEach((node) => {
+      (floors[node.floor] = floors[node.floor] || []).push(node);
+    });
+    floors.reverse().forEach((nodes) => {
+      const $floor = $("<div>").addClass("map-floor");
+      nodes.forEach((node) => {
+        $("<button>")
+          .addClass("map-node")
+          .toggleClass("visited", node.visited)
+          .toggleClass("current", node.id === run.position)
+          .text(nodeLabels[node.type] || node.type)
+          .prop("disabled", !choices.includes(node.id))
+          .click(() => chooseNode(node))
+          .appendTo($floor);
+      });
+      $map.append($floor);
+    });
+  }
+
+  function chooseNode(node) {
+    $.ajax({
+      url: "http://localhost:8080/map/choose",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ nodeId: node.id }),
+      success: function (response) {
+        renderMap(response.run, response.choices);
+        refreshCharacter();
+        if (["combat", "elite", "boss"].includes(node.type)) {
+          startCombat();
+          $("#combat-controls").show();
+        } else if (node.type === "shop") {
+          $("#shop").show();
+          $("#toggle-shop-btn").text("Leave Shop");
+          loadShop();
+        }
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+
+  $("#new-run-btn").click(function () {
+    $.ajax({
+      url: "http://localhost:8080/map/new-run",
+      type: "POST",
+      success: function (response) {
+        renderMap(response.run, response.choices);
+        refreshCharacter();
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  });
+  function rest(action, instanceId) {
+    $.ajax({
+      url: "http://localhost:8080/rest",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ action: action, instanceId: instanceId }),
+      success: function (response) {
+        renderMap(response.run, response.choices);
+        refreshCharacter();
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+
+  $("#rest-heal-btn").click(function () {
+    rest("heal");
+  });
+
+  $("#rest-upgrade-btn").click(function () {
+    rest("upgrade", $("#rest-upgrade-card").val());
+  });
+  //#endregion Dungeon Map
+
+  //#region Rewards
+  function loadRewards() {
+    $.ajax({
+      url: "http://localhost:8080/rewards",
+      type: "GET",
+      success: function (rewards) {
+        renderRewards(rewards);
+      },
+    });
+  }
+
+  function rewardName(reward) {
+    switch (reward.type) {
+      case "gold":
+        return `${reward.gold} gold`;
+      case "item":
+        return itemName(reward.itemId);
+      case "card": {
+        const card = getCardById(reward.cardId);
+        return card ? `${card.name} card` : `Card ${reward.cardId}`;
+      }
+    }
+    return "Choose a card:";
+  }
+
+  function renderRewards(rewards) {
+    const $list = $("#reward-list").empty();
+    $("#rewards").toggle(rewards.length > 0);
+    rewards.forEach((reward) => {
+      const $reward = $("<div>").addClass("reward").text(rewardName(reward) + " ");
+      if (reward.type === "cardChoice") {
+        reward.choices.forEach((id) => {
+          const card = getCardById(id);
+          $("<button>")
+            .text(card ? card.name : `Card ${id}`)
+            .click(() => claimReward(reward.id, id))
+            .appendTo($reward);
+        });
+        $("<button>").text("Skip").click(() => claimReward(reward.id, 0)).appendTo($reward);
+      } else {
+        $("<button>").text("Take").click(() => claimReward(reward.id, 0)).appendTo($reward);
+      }
+      $list.append($reward);
+    });
+  }
+
+  function claimReward(rewardId, cardId) {
+    $.ajax({
+      url: "http://localhost:8080/rewards/claim",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ rewardId: rewardId, cardId: cardId }),
+      success: function (character) {
+        displayCharacterInfo(character);
+        setPlayerDeck(character.deck);
+        renderRewards(character.rewards || []);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+  //#endregion Rewards
+
+  //#region Level Up
+  let statPoints = 0;
+
+  function refreshProgression() {
+    $.ajax({
+      url: "http://localhost:8080/progression",
+      type: "GET",
+      success: function (progress) {
+        $("#xp-next-display").text(
+          progress.xpForNextLevel ? ` / ${progress.xpForNextLevel}` : " (max level)"
+        );
+        statPoints = progress.statPoints;
+        $("#stat-points-display").text(statPoints);
+        $("#stat-points-info").toggle(statPoints > 0);
+        renderCardPick(progress.cardPicks || []);
+        $("#level-up").toggle(statPoints > 0 || (progress.cardPicks || []).length > 0);
+      },
+    });
+  }
+
+  function renderCardPick(cardPicks) {
+    const $pick = $("#card-pick").empty();
+    if (cardPicks.length === 0) {
+      return;
+    }
+    $pick.append(`<p>Choose a card to add to your deck (${cardPicks.length} pending):</p>`);
+    cardPicks[0].forEach((id) => {
+      const card = getCardById(id);
+      $("<button>")
+        .text(card ? card.name : `Card ${id}`)
+        .click(() => pickCard(id))
+        .appendTo($pick);
+    });
+    $("<button>").text("Skip").click(() => pickCard(0)).appendTo($pick);
+  }
+
+  function pickCard(cardId) {
+    $.ajax({
+      url: "http://localhost:8080/pick-card",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ cardId: cardId }),
+      success: function (character) {
+        displayCharacterInfo(character);
+        setPlayerDeck(character.deck);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+
+  $(".stat").click(function () {
+    if (statPoints <= 0) {
+      return;
+    }
+    $.ajax({
+      url: "http://localhost:8080/spend-stat-points",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ stat: $(this).data("stat"), points: 1 }),
+      success: function (character) {
+        displayCharacterInfo(character);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  });
+
+  let selectedBoostStat = null;
+
+  function renderBoostOffers(offers) {
+    $("#card-selection").toggle(offers.length > 0);
+    const $offers = $("#boost-offers").empty();
+    offers.forEach((offer) => {
+      $("<button>")
+        .addClass("stat-card")
+        .text(`+${offer.boost}`)
+        .click(() => claimStatBoost(offer.id))
+        .appendTo($offers);
+    });
+  }
+
+  $(".statb").click(function () {
+    $(".statb").removeClass("highlight");
+    $(this).addClass("highlight");
+    selectedBoostStat = $(this).data("statb");
+    $("#card-container").show();
+  });
+
+  function claimStatBoost(offerId) {
+    if (!selectedBoostStat) {
+      alert("Choose a stat to boost first.");
+      return;
+    }
+    $.ajax({
+      url: "http://localhost:8080/stat-boosts/claim",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ offerId: offerId, chosenStat: selectedBoostStat }),
+      success: function (character) {
+        selectedBoostStat = null;
+        $(".statb").removeClass("highlight");
+        $("#card-container").hide();
+        displayCharacterInfo(character);
+      },
+      error: function (xhr) {
+        alert(xhr.responseText);
+      },
+    });
+  }
+  //#endregion Level Up
 });
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Bump whenever a field of CombatResponse changes meaning or is removed.
+const combatAPIVersion = 2
+
+// CombatResponse is returned by every combat endpoint, whatever the outcome.
+type CombatResponse struct {
+	Version    int           `json:"version"`
+	Result     string        `json:"result"` // Plain-text summary of the events
+	Events     []CombatEvent `json:"events"` // What happened in this request, in order
+	Round      int           `json:"round"`
+	CombatOver bool          `json:"combatOver"`
+
+	PlayerHP      int `json:"playerHP"`
+	PlayerMaxHP   int `json:"playerMaxHP"`
+	PlayerMana    int `json:"playerMana"`
+	PlayerMaxMana int `json:"playerMaxMana"`
+
+	EnemyName   string  `json:"enemyName"`
+	EnemyHP     int     `json:"enemyHP"`
+	EnemyMaxHP  int     `json:"enemyMaxHP"`
+	EnemyIntent *Intent `json:"enemyIntent,omitempty"` // Only while the enemy is alive
+
+	Hand             []HandCard `json:"hand"`
+	DrawPileCount    int        `json:"drawPileCount"`
+	DiscardPileCount int        `json:"discardPileCount"`
+	ExhaustPileCount int        `json:"exhaustPileCount"`
+}
+
+// newCombatResponse snapshots the fight after the events recorded since start.
+func newCombatResponse(player *Character, enemy *Enemy, cd *CombatDeck, log *CombatLog, start int) CombatResponse {
+	events := log.Since(start)
+	response := CombatResponse{
+		Version:       combatAPIVersion,
+		Result:        narrate(events),
+		Events:        events,
+		Round:         log.Round,
+		CombatOver:    player.Health <= 0 || enemy.Health <= 0,
+		PlayerHP:      player.Health,
+		PlayerMaxHP:   player.MaxHealth,
+		PlayerMana:    player.Mana,
+		PlayerMaxMana: player.MaxMana,
+		EnemyName:     enemy.Name,
+		EnemyHP:       enemy.Health,
+		EnemyMaxHP:    enemy.MaxHealth,
+		Hand:          []HandCard{},
+	}
+	if enemy.Health > 0 {
+		response.EnemyIntent = enemy.Intent
+	}
+	if cd != nil {
+		response.Hand = cd.handCards()
+		response.DrawPileCount = len(cd.DrawPile)
+		response.DiscardPileCount = len(cd.DiscardPile)
+		response.ExhaustPileCount = len(cd.ExhaustPile)
+	}
+	return response
+}
+
+// Schemas published at /schema/{name}, generated from the response structs.
+var apiSchemas = map[string]reflect.Type{
+	"combat":    reflect.TypeOf(CombatResponse{}),
+	"card":      reflect.TypeOf(Card{}),
+	"enemy":     reflect.TypeOf(Enemy{}),
+	"character": reflect.TypeOf(Character{}),
+}
+
+// jsonSchema describes t as a JSON Schema document. Nested structs are
+// placed in $defs and referenced by name.
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	defs := map[string]interface{}{}
+	var schema map[string]interface{}
+	if t.Kind() == reflect.Struct {
+		schema = objectSchema(t, defs)
+	} else {
+		schema = schemaFor(t, defs)
+	}
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = t.Name()
+	if len(defs) > 0 {
+		schema["$defs"] = defs
+	}
+	return schema
+}
+
+func schemaFor(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), defs)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		// encoding/json writes nil slices and maps as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": schemaFor(t.Elem(), defs)}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		return structSchema(t, defs)
+	}
+	// interface{} and anything else accepts any JSON value
+	return map[string]interface{}{}
+}
+
+// structSchema returns a $ref to the object schema of a nested struct,
+// adding it to defs the first time the struct is seen.
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	ref := map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	if _, seen := defs[t.Name()]; seen {
+		return ref
+	}
+	// Reserve the name first so self-referencing types terminate
+	defs[t.Name()] = map[string]interface{}{}
+	defs[t.Name()] = objectSchema(t, defs)
+	return ref
+}
+
+// objectSchema describes the fields of struct t as an object schema.
+func objectSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	addStructFields(t, defs, properties, &required)
+
+	object := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// addStructFields collects the JSON fields of t, flattening embedded structs
+// the same way encoding/json does.
+func addStructFields(t reflect.Type, defs map[string]interface{}, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, defs, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, defs)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t, ok := apiSchemas[r.PathValue("name")]
+	if !ok {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+
+	schema := jsonSchema(t)
+	// Only the combat response is versioned
+	if t == apiSchemas["combat"] {
+		schema["version"] = combatAPIVersion
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(schema)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Damage resolution
+//
+// Every direct hit goes through resolveDamage in this order:
+//
+//  1. Dodge: attacks (not spells) can be dodged. The chance starts at
+//     baseDodgeChance and moves by dodgePerPoint for every point the
+//     defender's Agility exceeds the attacker's Dexterity, capped at maxDodgeChance.
+//  2. Critical hit: baseCritChance plus critPerLuck per point of Luck and
+//     critPerPerception per point of Perception, capped at maxCritChance.
+//     Crits multiply the damage by critMultiplier.
+//  3. Mitigation: physical damage is reduced by armor / (armor + armorFactor),
+//     so every point of armor helps a little less than the last. Fire, frost and
+//     shadow damage ignore armor and are scaled by the defender's resistance
+//     instead (0.25 = takes 25% less, -0.5 = takes 50% more).
+//
+// A hit that lands always deals at least 1 damage.
+
+const (
+	damagePhysical = "physical"
+	damageFire     = "fire"
+	damageFrost    = "frost"
+	damageShadow   = "shadow"
+)
+
+var damageTypes = map[string]bool{
+	damagePhysical: true,
+	damageFire:     true,
+	damageFrost:    true,
+	damageShadow:   true,
+}
+
+const (
+	baseDodgeChance   = 0.05
+	dodgePerPoint     = 0.01
+	maxDodgeChance    = 0.4
+	baseCritChance    = 0.05
+	critPerLuck       = 0.005
+	critPerPerception = 0.0025
+	maxCritChance     = 0.5
+	critMultiplier    = 1.5
+	armorFactor       = 50.0
+	maxResistance     = 0.9
+)
+
+// DamageBreakdown explains how a single hit was resolved so the client can show it.
+type DamageBreakdown struct {
+	Attacker   string `json:"attacker"`
+	Defender   string `json:"defender"`
+	DamageType string `json:"damageType"`
+	Base       int    `json:"base"` // Damage before crits and mitigation
+	Dodged     bool   `json:"dodged"`
+	Critical   bool   `json:"critical"`
+	Mitigated  int    `json:"mitigated"` // Damage absorbed by armor or resistance
+	Final      int    `json:"final"`     // Damage actually dealt
+}
+
+// resolveDamage rolls a hit from attacker against defender and applies it.
+func resolveDamage(attacker Target, defender Target, base int, damageType string, canDodge bool) DamageBreakdown {
+	if damageType == "" {
+		damageType = damagePhysical
+	}
+	hit := DamageBreakdown{
+		Attacker:   attacker.GetName(),
+		Defender:   defender.GetName(),
+		DamageType: damageType,
+		Base:       base,
+	}
+
+	if canDodge && rand.Float64() < dodgeChance(attacker, defender) {
+		hit.Dodged = true
+		return hit
+	}
+
+	damage := float64(base)
+	if rand.Float64() < critChance(attacker) {
+		hit.Critical = true
+		damage *= critMultiplier
+	}
+
+	var reduction float64
+	if damageType == damagePhysical {
+		armor := float64(defender.EffectiveStat("armor"))
+		reduction = armor / (armor + armorFactor)
+	} else {
+		reduction = clamp(defender.Resistance(damageType), -1, maxResistance)
+	}
+
+	final := int(damage * (1 - reduction))
+	if final < 1 && base > 0 {
+		final = 1
+	}
+	hit.Mitigated = int(damage) - final
+	hit.Final = defender.ReceiveDamage(final)
+	return hit
+}
+
+func dodgeChance(attacker Target, defender Target) float64 {
+	diff := defender.EffectiveStat("agility") - attacker.EffectiveStat("dexterity")
+	return clamp(baseDodgeChance+dodgePerPoint*float64(diff), 0, maxDodgeChance)
+}
+
+func critChance(attacker Target) float64 {
+	chance := baseCritChance +
+		critPerLuck*float64(attacker.EffectiveStat("luck")) +
+		critPerPerception*float64(attacker.EffectiveStat("perception"))
+	return clamp(chance, 0, maxCritChance)
+}
+
+func clamp(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// Resistance is the character's damage reduction against the given type.
+func (c *Character) Resistance(damageType string) float64 {
+	resistance := 0.0
+	for _, trait := range c.traits() {
+		resistance += trait.Resistances[damageType]
+	}
+	for _, item := range c.equippedItems() {
+		resistance += item.Resistances[damageType]
+	}
+	return resistance
+}
+
+// Resistance is the enemy's damage reduction against the given type.
+func (e *Enemy) Resistance(damageType string) float64 {
+	resistance := e.Resistances[damageType]
+	if weapon := e.weaponItem(); weapon != nil {
+		resistance += weapon.Resistances[damageType]
+	}
+	return resistance
+}
+
+// describeHit turns a resolved hit into a combat log sentence.
+func describeHit(hit DamageBreakdown) string {
+	if hit.Dodged {
+		return fmt.Sprintf(" %s dodges %s's attack!", hit.Defender, hit.Attacker)
+	}
+	text := fmt.Sprintf(" %s takes %d %s damage.", hit.Defender, hit.Final, hit.DamageType)
+	if hit.Critical {
+		text = " Critical hit!" + text
+	}
+	return text
+}
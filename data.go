@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// readDataDir decodes every *.json file in dir, each holding a list of T,
+// and returns the entries of all files in file name order.
+func readDataDir[T any](dir string) ([]T, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no data files found in %s", dir)
+	}
+
+	var entries []T
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var fileEntries []T
+		if err := json.Unmarshal(data, &fileEntries); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
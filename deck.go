@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+)
+
+const (
+	startingHandSize = 5 // Cards drawn when combat starts
+	drawPerTurn      = 1 // Cards drawn at the start of each following turn
+	maxHandSize      = 7 // Draws beyond this are skipped
+)
+
+// Every new character starts with this deck until they build their own.
+var defaultStarterDeck = []int{1, 1, 2, 2, 3, 3, 4, 4}
+
+// CardInstance is one copy of a card in a character's deck. Each copy has
+// its own ID so copies of the same card can be upgraded separately.
+type CardInstance struct {
+	ID      string `json:"id"`
+	CardID  int    `json:"cardId"`
+	Upgrade int    `json:"upgrade"` // 0 for the base card
+}
+
+func newCardInstance(cardID int) CardInstance {
+	return CardInstance{ID: fmt.Sprintf("%016x", rand.Uint64()), CardID: cardID}
+}
+
+// newCardInstances makes a fresh copy of every listed card.
+func newCardInstances(cardIDs []int) []CardInstance {
+	instances := make([]CardInstance, 0, len(cardIDs))
+	for _, id := range cardIDs {
+		instances = append(instances, newCardInstance(id))
+	}
+	return instances
+}
+
+// Card returns the card this copy plays as, upgrades included, or nil if
+// the card is no longer in the catalog.
+func (ci CardInstance) Card() *Card {
+	card := getCardByID(ci.CardID)
+	if card == nil {
+		return nil
+	}
+	upgraded := card.upgradedTo(ci.Upgrade)
+	return &upgraded
+}
+
+// canUpgrade reports whether the copy has an upgrade left.
+func (ci CardInstance) canUpgrade() bool {
+	card := getCardByID(ci.CardID)
+	return card != nil && card.Upgraded != nil && ci.Upgrade < maxCardUpgrade
+}
+
+// addCard puts a fresh copy of a card into the character's deck.
+func (c *Character) addCard(cardID int) {
+	c.Deck = append(c.Deck, newCardInstance(cardID))
+}
+
+// deckCard returns the copy in the deck with the given instance ID, or nil.
+func (c *Character) deckCard(instanceID string) *CardInstance {
+	for i := range c.Deck {
+		if c.Deck[i].ID == instanceID {
+			return &c.Deck[i]
+		}
+	}
+	return nil
+}
+
+// removeCard takes a copy out of the deck, reporting whether it was there.
+func (c *Character) removeCard(instanceID string) bool {
+	for i := range c.Deck {
+		if c.Deck[i].ID == instanceID {
+			c.Deck = append(c.Deck[:i], c.Deck[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// HandCard is a card in hand, shown as it will play.
+type HandCard struct {
+	InstanceID string `json:"instanceId"`
+	Upgrade    int    `json:"upgrade"`
+	Card
+}
+
+// CombatDeck tracks where each card of the character's deck is during a fight.
+type CombatDeck struct {
+	DrawPile    []CardInstance `json:"drawPile"`
+	Hand        []CardInstance `json:"hand"`
+	DiscardPile []CardInstance `json:"discardPile"`
+	ExhaustPile []CardInstance `json:"exhaustPile"`
+}
+
+// newCombatDeck shuffles the deck list into a fresh draw pile and deals the opening hand.
+func newCombatDeck(deck []CardInstance) *CombatDeck {
+	cd := &CombatDeck{
+		DrawPile:    append([]CardInstance(nil), deck...),
+		Hand:        []CardInstance{},
+		DiscardPile: []CardInstance{},
+		ExhaustPile: []CardInstance{},
+	}
+	shuffleCards(cd.DrawPile)
+	cd.Draw(startingHandSize)
+	return cd
+}
+
+func shuffleCards(cards []CardInstance) {
+	rand.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+}
+
+// Draw moves up to n cards from the draw pile into the hand, reshuffling the
+// discard pile into the draw pile whenever it runs out.
+func (cd *CombatDeck) Draw(n int) []CardInstance {
+	var drawn []CardInstance
+	for i := 0; i < n && len(cd.Hand) < maxHandSize; i++ {
+		if len(cd.DrawPile) == 0 {
+			if len(cd.DiscardPile) == 0 {
+				break
+			}
+			cd.DrawPile = cd.DiscardPile
+			cd.DiscardPile = []CardInstance{}
+			shuffleCards(cd.DrawPile)
+		}
+		card := cd.DrawPile[0]
+		cd.DrawPile = cd.DrawPile[1:]
+		cd.Hand = append(cd.Hand, card)
+		drawn = append(drawn, card)
+	}
+	return drawn
+}
+
+// InHand returns the copy in hand with the given instance ID, or nil.
+func (cd *CombatDeck) InHand(instanceID string) *CardInstance {
+	for i := range cd.Hand {
+		if cd.Hand[i].ID == instanceID {
+			return &cd.Hand[i]
+		}
+	}
+	return nil
+}
+
+// Play removes a copy from the hand and puts it on the discard pile, or the
+// exhaust pile if the card exhausts.
+func (cd *CombatDeck) Play(instanceID string, card *Card) bool {
+	for i, instance := range cd.Hand {
+		if instance.ID != instanceID {
+			continue
+		}
+		cd.Hand = append(cd.Hand[:i], cd.Hand[i+1:]...)
+		if card.Exhaust {
+			cd.ExhaustPile = append(cd.ExhaustPile, instance)
+		} else {
+			cd.DiscardPile = append(cd.DiscardPile, instance)
+		}
+		return true
+	}
+	return false
+}
+
+// handCards resolves the hand into the cards as they will play.
+func (cd *CombatDeck) handCards() []HandCard {
+	hand := []HandCard{}
+	for _, instance := range cd.Hand {
+		if card := instance.Card(); card != nil {
+			hand = append(hand, HandCard{InstanceID: instance.ID, Upgrade: instance.Upgrade, Card: *card})
+		}
+	}
+	return hand
+}
+
+// DeckHandler shows the character's deck. Cards only join the deck through
+// the merchant, rewards and level-ups, and only leave it at the merchant.
+func DeckHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player.Deck)
+}
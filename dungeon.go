@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+)
+
+// A run is a climb through a few acts. Each act is a map of floors with a
+// handful of nodes each; the character starts below the first floor, moves
+// up one floor at a time along the paths out of its node and meets the boss
+// on the last floor. The run is stored with the character, so saving and
+// loading keeps the map and the character's place on it.
+
+// Node types.
+const (
+	nodeCombat = "combat"
+	nodeElite  = "elite"
+	nodeRest   = "rest"
+	nodeShop   = "shop"
+	nodeEvent  = "event"
+	nodeBoss   = "boss"
+)
+
+// Run states.
+const (
+	runActive   = "active"
+	runDefeated = "defeated"
+	runWon      = "won"
+)
+
+// Map shape.
+const (
+	actsPerRun    = 3
+	mapFloors     = 10 // Including the boss floor
+	mapLanes      = 3
+	eliteMinFloor = 3 // Elites don't show up on the first few floors
+)
+
+// How much harder elites, bosses and later acts are than the character's level.
+const (
+	eliteLevelBonus = 2
+	bossLevelBonus  = 3
+	actLevelBonus   = 1
+)
+
+// How often each node type shows up on the middle floors.
+var nodeWeights = []struct {
+	Type   string
+	Weight int
+}{
+	{nodeCombat, 50},
+	{nodeEvent, 20},
+	{nodeElite, 12},
+	{nodeRest, 10},
+	{nodeShop, 8},
+}
+
+// Run is the character's current climb.
+type Run struct {
+	Act      int       `json:"act"`
+	Status   string    `json:"status"` // "active", "defeated" or "won"
+	Nodes    []MapNode `json:"nodes"`
+	Position int       `json:"position"` // ID of the node the character is on, -1 before the first floor
+}
+
+// MapNode is one stop on the act map.
+type MapNode struct {
+	ID      int    `json:"id"`
+	Floor   int    `json:"floor"`
+	Lane    int    `json:"lane"`
+	Type    string `json:"type"`
+	Next    []int  `json:"next"` // Nodes on the floor above reachable from this one
+	Visited bool   `json:"visited"`
+	Cleared bool   `json:"cleared"`           // Fought, rested or otherwise dealt with
+	Outcome string `json:"outcome,omitempty"` // What happened at a rest site or event
+}
+
+func newRun() *Run {
+	return &Run{Act: 1, Status: runActive, Nodes: generateMap(), Position: -1}
+}
+
+// generateMap lays out an act: combat on the first floor, a rest site
+// before the boss and a weighted mix of everything in between. Every node
+// leads up to the node in its own lane and sometimes to a neighbouring lane,
+// without paths crossing.
+func generateMap() []MapNode {
+	var nodes []MapNode
+	for floor := 0; floor < mapFloors-1; floor++ {
+		for lane := 0; lane < mapLanes; lane++ {
+			nodes = append(nodes, MapNode{ID: len(nodes), Floor: floor, Lane: lane, Type: rollNodeType(floor), Next: []int{}})
+		}
+	}
+	boss := MapNode{ID: len(nodes), Floor: mapFloors - 1, Lane: mapLanes / 2, Type: nodeBoss, Next: []int{}}
+
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Floor == mapFloors-2 {
+			node.Next = append(node.Next, boss.ID)
+			continue
+		}
+		above := node.ID + mapLanes
+		node.Next = append(node.Next, above)
+		if rand.Intn(2) == 0 {
+			continue
+		}
+		side := node.Lane - 1 + 2*rand.Intn(2)
+		if side < 0 || side >= mapLanes {
+			continue
+		}
+		// A path from the left neighbour into this lane would cross one going left
+		if side < node.Lane && containsInt(nodes[i-1].Next, above) {
+			continue
+		}
+		node.Next = append(node.Next, above+side-node.Lane)
+	}
+
+	return append(nodes, boss)
+}
+
+func rollNodeType(floor int) string {
+	switch floor {
+	case 0:
+		return nodeCombat
+	case mapFloors - 2:
+		return nodeRest
+	}
+
+	total := 0
+	for _, weight := range nodeWeights {
+		if weight.Type == nodeElite && floor < eliteMinFloor {
+			continue
+		}
+		total += weight.Weight
+	}
+	roll := rand.Intn(total)
+	for _, weight := range nodeWeights {
+		if weight.Type == nodeElite && floor < eliteMinFloor {
+			continue
+		}
+		roll -= weight.Weight
+		if roll < 0 {
+			return weight.Type
+		}
+	}
+	return nodeCombat
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the node the character is on, or nil before the first floor.
+func (r *Run) current() *MapNode {
+	if r == nil || r.Position < 0 || r.Position >= len(r.Nodes) {
+		return nil
+	}
+	return &r.Nodes[r.Position]
+}
+
+// choices returns the IDs of the nodes the character can move to next. The
+// current node has to be cleared before moving on.
+func (r *Run) choices() []int {
+	if r == nil || r.Status != runActive {
+		return []int{}
+	}
+	node := r.current()
+	if node == nil {
+		ids := []int{}
+		for _, n := range r.Nodes {
+			if n.Floor == 0 {
+				ids = append(ids, n.ID)
+			}
+		}
+		return ids
+	}
+	if !node.Cleared {
+		return []int{}
+	}
+	return node.Next
+}
+
+// at reports whether the character stands on a node of the given type.
+func (r *Run) at(nodeType string) bool {
+	node := r.current()
+	return node != nil && node.Type == nodeType
+}
+
+// pendingFight returns the current node if it is a fight that hasn't been won yet.
+func (r *Run) pendingFight() *MapNode {
+	node := r.current()
+	if node == nil || node.Cleared || r.Status != runActive {
+		return nil
+	}
+	switch node.Type {
+	case nodeCombat, nodeElite, nodeBoss:
+		return node
+	}
+	return nil
+}
+
+// nodeEncounter generates the enemy waiting at a fight node.
+func nodeEncounter(run *Run, node *MapNode, level int) *Enemy {
+	level += (run.Act - 1) * actLevelBonus
+	switch node.Type {
+	case nodeElite:
+		return generateEncounter(level + eliteLevelBonus)
+	case nodeBoss:
+		return generateBoss(level + bossLevelBonus)
+	}
+	return generateEncounter(level)
+}
+
+// generateBoss picks the strongest enemy in the bestiary, scaled up to level
+// if it falls short of it.
+func generateBoss(level int) *Enemy {
+	var boss *Enemy
+	for _, enemy := range bestiary.All() {
+		if boss == nil || enemy.Level > boss.Level {
+			e := enemy
+			boss = &e
+		}
+	}
+	if boss == nil {
+		return nil
+	}
+	return scaleEnemy(*boss, level)
+}
+
+// finishFight settles the current fight node once a fight is decided. Beating
+// the boss moves the run to the next act, or wins it after the last one.
+func (r *Run) finishFight(won bool) {
+	node := r.pendingFight()
+	if node == nil {
+		return
+	}
+	if !won {
+		r.Status = runDefeated
+		return
+	}
+	node.Cleared = true
+	if node.Type != nodeBoss {
+		return
+	}
+	if r.Act >= actsPerRun {
+		r.Status = runWon
+		return
+	}
+	r.Act++
+	r.Nodes = generateMap()
+	r.Position = -1
+}
+
+// enterNode moves the character onto a node. Fights and rest sites wait for
+// the character to act on them; everything else is resolved straight away.
+func enterNode(c *Character, node *MapNode) {
+	c.Run.Position = node.ID
+	node.Visited = true
+
+	switch node.Type {
+	case nodeShop:
+		// Each merchant on the map has their own stock
+		c.Shop = newShop(c)
+		node.Cleared = true
+	case nodeEvent:
+		event := dungeonEvents[rand.Intn(len(dungeonEvents))]
+		node.Outcome = event(c)
+		node.Cleared = true
+	}
+}
+
+// dungeonEvents are the things that can happen at an event node. Each one
+// changes the character and describes what happened.
+var dungeonEvents = []func(c *Character) string{
+	func(c *Character) string {
+		gold := 20 + rand.Intn(31)
+		c.Gold += gold
+		return fmt.Sprintf("You search an abandoned camp and find %d gold.", gold)
+	},
+	func(c *Character) string {
+		damage := c.MaxHealth / 10
+		c.Health = max(c.Health-damage, 1)
+		return fmt.Sprintf("A spike trap catches you for %d damage.", damage)
+	},
+	func(c *Character) string {
+		grantStatBoostOffers(c, 1)
+		return "A wandering sage offers to train you. A stat boost awaits."
+	},
+	func(c *Character) string {
+		c.CardPicks = append(c.CardPicks, offerCardPick(c.Class))
+		return "You find a forgotten shrine. A new card calls to you."
+	},
+}
+
+// MapHandler shows the current act map. Characters without a run start one.
+func MapHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.Player.Run == nil {
+		s.Player.Run = newRun()
+	}
+	writeMap(w, s.Player.Run)
+}
+
+// ChooseNodeHandler moves the character to one of the nodes reachable from
+// its current position.
+func ChooseNodeHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't travel during combat", http.StatusConflict)
+		return
+	}
+
+	var chooseData struct {
+		NodeID int `json:"nodeId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&chooseData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	run := s.Player.Run
+	if run == nil || run.Status != runActive {
+		http.Error(w, "The run is over", http.StatusConflict)
+		return
+	}
+	if !containsInt(run.choices(), chooseData.NodeID) {
+		http.Error(w, "Node not reachable", http.StatusBadRequest)
+		return
+	}
+
+	enterNode(&s.Player, &run.Nodes[chooseData.NodeID])
+	s.Enemy = nil
+	checkpoint(s, checkpointNodeChoice)
+	writeMap(w, run)
+}
+
+// NewRunHandler starts a fresh run once the last one has ended, back at full
+// health and mana. The character keeps its level, deck and gear.
+func NewRunHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Player.Run != nil && s.Player.Run.Status == runActive {
+		http.Error(w, "The current run isn't over", http.StatusConflict)
+		return
+	}
+
+	s.Player.Run = newRun()
+	s.Player.Health = s.Player.MaxHealth
+	s.Player.Mana = s.Player.MaxMana
+	s.Player.Shop = nil
+	s.Enemy = nil
+	s.CombatDeck = nil
+	writeMap(w, s.Player.Run)
+}
+
+func writeMap(w http.ResponseWriter, run *Run) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run":     run,
+		"choices": run.choices(),
+	})
+}
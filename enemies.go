@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+)
+
+// LootEntry is one weighted drop in an enemy's loot table.
+type LootEntry struct {
+	Type   string `json:"type"`             // "gold", "card" or "item"
+	CardID int    `json:"cardId,omitempty"` // Card dropped by "card" entries
+	ItemID string `json:"itemId,omitempty"` // Item dropped by "item" entries
+	Min    int    `json:"min,omitempty"`    // Gold range for "gold" entries
+	Max    int    `json:"max,omitempty"`
+	Weight int    `json:"weight"`
+}
+
+// Bestiary holds every enemy definition known to the server.
+type Bestiary struct {
+	enemies map[string]Enemy
+	order   []string
+}
+
+var bestiary = &Bestiary{enemies: make(map[string]Enemy)}
+
+const (
+	encounterLevelRange  = 1    // Enemies within this many levels of the character can be picked
+	enemyScalingPerLevel = 0.15 // Stat and reward increase per level an enemy is raised
+)
+
+// loadBestiary reads every enemy file in dir and replaces the bestiary once
+// all of them have been validated. Cards and items must be loaded first.
+func loadBestiary(dir string) error {
+	enemies, err := readDataDir[Enemy](dir)
+	if err != nil {
+		return err
+	}
+
+	b := &Bestiary{enemies: make(map[string]Enemy)}
+	for _, enemy := range enemies {
+		if err := validateEnemy(enemy); err != nil {
+			return err
+		}
+		if _, exists := b.enemies[enemy.ID]; exists {
+			return fmt.Errorf("duplicate enemy id %q", enemy.ID)
+		}
+		b.enemies[enemy.ID] = enemy
+		b.order = append(b.order, enemy.ID)
+	}
+	sort.SliceStable(b.order, func(i, j int) bool {
+		return b.enemies[b.order[i]].Level < b.enemies[b.order[j]].Level
+	})
+
+	bestiary = b
+	return nil
+}
+
+func validateEnemy(enemy Enemy) error {
+	if enemy.ID == "" {
+		return fmt.Errorf("enemy %q has no id", enemy.Name)
+	}
+	if enemy.Name == "" {
+		return fmt.Errorf("enemy %q has no name", enemy.ID)
+	}
+	if enemy.MaxHealth <= 0 {
+		return fmt.Errorf("enemy %q needs a positive maxHealth", enemy.ID)
+	}
+	if enemy.Level < 1 {
+		return fmt.Errorf("enemy %q needs a level of at least 1", enemy.ID)
+	}
+	if enemy.ExperienceReward < 0 {
+		return fmt.Errorf("enemy %q has a negative experienceReward", enemy.ID)
+	}
+
+	if enemy.Weapon != "" && itemCatalog.Get(enemy.Weapon) == nil {
+		return fmt.Errorf("enemy %q wields unknown item %q", enemy.ID, enemy.Weapon)
+	}
+
+	if _, ok := enemyPolicies[enemy.AI]; enemy.AI != "" && !ok {
+		return fmt.Errorf("enemy %q has unknown ai %q", enemy.ID, enemy.AI)
+	}
+
+	for damageType := range enemy.Resistances {
+		if !damageTypes[damageType] {
+			return fmt.Errorf("enemy %q resists unknown damage type %q", enemy.ID, damageType)
+		}
+	}
+
+	for _, ability := range enemy.Abilities {
+		if ability.Name == "" {
+			return fmt.Errorf("enemy %q has an unnamed ability", enemy.ID)
+		}
+		if len(ability.Effects) == 0 {
+			return fmt.Errorf("enemy %q ability %q has no effects", enemy.ID, ability.Name)
+		}
+		for _, effect := range ability.Effects {
+			if err := validateEffect(effect); err != nil {
+				return fmt.Errorf("enemy %q ability %q: %w", enemy.ID, ability.Name, err)
+			}
+		}
+	}
+
+	for _, loot := range enemy.LootTable {
+		if loot.Weight <= 0 {
+			return fmt.Errorf("enemy %q has a loot entry without a positive weight", enemy.ID)
+		}
+		switch loot.Type {
+		case "gold":
+			if loot.Min < 0 || loot.Max < loot.Min {
+				return fmt.Errorf("enemy %q has an invalid gold range", enemy.ID)
+			}
+		case "card":
+			if getCardByID(loot.CardID) == nil {
+				return fmt.Errorf("enemy %q drops unknown card %d", enemy.ID, loot.CardID)
+			}
+		case "item":
+			if itemCatalog.Get(loot.ItemID) == nil {
+				return fmt.Errorf("enemy %q drops unknown item %q", enemy.ID, loot.ItemID)
+			}
+		default:
+			return fmt.Errorf("enemy %q has unknown loot type %q", enemy.ID, loot.Type)
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the enemy definition with the given ID, or nil.
+func (b *Bestiary) Get(id string) *Enemy {
+	enemy, ok := b.enemies[id]
+	if !ok {
+		return nil
+	}
+	return &enemy
+}
+
+// All returns every enemy definition, weakest first.
+func (b *Bestiary) All() []Enemy {
+	enemies := make([]Enemy, 0, len(b.order))
+	for _, id := range b.order {
+		enemies = append(enemies, b.enemies[id])
+	}
+	return enemies
+}
+
+// generateEncounter picks a random enemy suited to a character of the given
+// level and returns a fresh, full-health instance of it.
+func generateEncounter(level int) *Enemy {
+	all := bestiary.All()
+	if len(all) == 0 {
+		return nil
+	}
+
+	var candidates []Enemy
+	for _, enemy := range all {
+		if abs(enemy.Level-level) <= encounterLevelRange {
+			candidates = append(candidates, enemy)
+		}
+	}
+
+	// Nothing close enough: fall back to the enemies nearest in level
+	if len(candidates) == 0 {
+		closest := -1
+		for _, enemy := range all {
+			diff := abs(enemy.Level - level)
+			if closest == -1 || diff < closest {
+				closest = diff
+				candidates = candidates[:0]
+			}
+			if diff == closest {
+				candidates = append(candidates, enemy)
+			}
+		}
+	}
+
+	return scaleEnemy(candidates[rand.Intn(len(candidates))], level)
+}
+
+// scaleEnemy raises an enemy that is weaker than the character up to the
+// character's level, growing its stats and rewards accordingly.
+func scaleEnemy(definition Enemy, level int) *Enemy {
+	enemy := definition
+	if diff := level - definition.Level; diff > 0 {
+		factor := 1 + enemyScalingPerLevel*float64(diff)
+		enemy.MaxHealth = int(float64(definition.MaxHealth) * factor)
+		enemy.Strength = int(float64(definition.Strength) * factor)
+		enemy.Dexterity = int(float64(definition.Dexterity) * factor)
+		enemy.Intelligence = int(float64(definition.Intelligence) * factor)
+		enemy.ExperienceReward = int(float64(definition.ExperienceReward) * factor)
+		enemy.Abilities = scaleAbilities(definition.Abilities, factor)
+		enemy.LootTable = make([]LootEntry, len(definition.LootTable))
+		for i, loot := range definition.LootTable {
+			if loot.Type == "gold" {
+				loot.Min = int(float64(loot.Min) * factor)
+				loot.Max = int(float64(loot.Max) * factor)
+			}
+			enemy.LootTable[i] = loot
+		}
+		enemy.Level = level
+	}
+	enemy.Health = enemy.MaxHealth
+	return &enemy
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func BestiaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bestiary.All())
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Intent telegraphs what an enemy will do on its next turn.
+type Intent struct {
+	Ability     string `json:"ability"`
+	Type        string `json:"type"`   // "attack", "debuff", "buff" or "heal"
+	Damage      int    `json:"damage"` // Direct damage the ability will deal, if any
+	Description string `json:"description"`
+
+	card Card
+}
+
+// EnemyPolicy decides which ability an enemy will use on its next turn.
+type EnemyPolicy interface {
+	ChooseAbility(enemy *Enemy, player *Character) Card
+}
+
+// Policies enemies can name in their "ai" field. Enemies without one act randomly.
+var enemyPolicies = map[string]EnemyPolicy{
+	"random":   randomPolicy{},
+	"cycle":    cyclePolicy{},
+	"cautious": cautiousPolicy{},
+}
+
+const defaultEnemyPolicy = "random"
+
+// Health fraction below which a cautious enemy prefers healing.
+const cautiousHealThreshold = 0.4
+
+// randomPolicy picks any of the enemy's abilities with equal odds.
+type randomPolicy struct{}
+
+func (randomPolicy) ChooseAbility(enemy *Enemy, player *Character) Card {
+	abilities := enemyAbilities(enemy)
+	return abilities[rand.Intn(len(abilities))]
+}
+
+// cyclePolicy uses the enemy's abilities in the order they are listed.
+type cyclePolicy struct{}
+
+func (cyclePolicy) ChooseAbility(enemy *Enemy, player *Character) Card {
+	abilities := enemyAbilities(enemy)
+	return abilities[enemy.Turn%len(abilities)]
+}
+
+// cautiousPolicy heals when badly hurt and otherwise picks a random non-healing ability.
+type cautiousPolicy struct{}
+
+func (cautiousPolicy) ChooseAbility(enemy *Enemy, player *Character) Card {
+	abilities := enemyAbilities(enemy)
+
+	var heals, others []Card
+	for _, ability := range abilities {
+		if intentType(ability) == "heal" {
+			heals = append(heals, ability)
+		} else {
+			others = append(others, ability)
+		}
+	}
+
+	hurt := float64(enemy.Health) < float64(enemy.MaxHealth)*cautiousHealThreshold
+	if (hurt && len(heals) > 0) || len(others) == 0 {
+		return heals[rand.Intn(len(heals))]
+	}
+	return others[rand.Intn(len(others))]
+}
+
+// enemyAbilities returns the enemy's abilities, falling back to a plain
+// strength-based attack for enemies that don't define any.
+func enemyAbilities(enemy *Enemy) []Card {
+	if len(enemy.Abilities) > 0 {
+		return enemy.Abilities
+	}
+	amount := enemy.EffectiveStat("strength") * 2
+	if weapon := enemy.weaponItem(); weapon != nil {
+		amount += weapon.Damage
+	}
+	return []Card{{
+		Name: "Attack",
+		Type: "attack",
+		Effects: []Effect{{
+			Type:        "damage",
+			Target:      "enemy",
+			Parameters:  map[string]interface{}{"amount": amount},
+			Description: fmt.Sprintf("Attacks for %d damage.", amount),
+		}},
+	}}
+}
+
+// planEnemyTurn asks the enemy's policy for its next ability and telegraphs it.
+func planEnemyTurn(enemy *Enemy, player *Character) {
+	policy, ok := enemyPolicies[enemy.AI]
+	if !ok {
+		policy = enemyPolicies[defaultEnemyPolicy]
+	}
+
+	ability := policy.ChooseAbility(enemy, player)
+	intent := &Intent{
+		Ability: ability.Name,
+		Type:    intentType(ability),
+		card:    ability,
+	}
+	for _, effect := range ability.Effects {
+		if effect.Target != "enemy" {
+			continue
+		}
+		if effect.Type == "damage" || effect.Type == "lifeSteal" {
+			amount, _ := getFloatParameter(effect.Parameters, "amount")
+			intent.Damage += enemy.modifyStat("attack", int(amount))
+		}
+		if intent.Description != "" {
+			intent.Description += " "
+		}
+		intent.Description += effect.Description
+	}
+	if intent.Description == "" && len(ability.Effects) > 0 {
+		intent.Description = ability.Effects[0].Description
+	}
+	enemy.Intent = intent
+}
+
+// intentType classifies an ability by the most threatening thing it does.
+func intentType(ability Card) string {
+	kind := ""
+	for _, effect := range ability.Effects {
+		switch effect.Type {
+		case "damage", "damageOverTime", "lifeSteal":
+			return "attack"
+		case "statusEffect":
+			kind = "debuff"
+		case "buff":
+			if kind == "" || kind == "heal" {
+				kind = "buff"
+			}
+		case "heal", "healOverTime":
+			if kind == "" {
+				kind = "heal"
+			}
+		}
+	}
+	return kind
+}
+
+// enemyTakeTurn carries out the enemy's telegraphed intent and plans its next one.
+func enemyTakeTurn(enemy *Enemy, player *Character, log *CombatLog) {
+	if enemy.Intent == nil {
+		planEnemyTurn(enemy, player)
+	}
+	ability := enemy.Intent.card
+	applyCardEffects(&ability, enemy, player, log)
+
+	enemy.Turn++
+	planEnemyTurn(enemy, player)
+}
+
+// scaleAbilities returns copies of the abilities with their amounts multiplied by factor.
+func scaleAbilities(abilities []Card, factor float64) []Card {
+	scaled := make([]Card, len(abilities))
+	for i, ability := range abilities {
+		scaled[i] = ability
+		scaled[i].Effects = make([]Effect, len(ability.Effects))
+		for j, effect := range ability.Effects {
+			params := make(map[string]interface{}, len(effect.Parameters))
+			for key, value := range effect.Parameters {
+				params[key] = value
+			}
+			if amount, ok := getFloatParameter(params, "amount"); ok {
+				params["amount"] = int(amount * factor)
+			}
+			effect.Parameters = params
+			scaled[i].Effects[j] = effect
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Combat event types
+const (
+	eventCombatStarted  = "combatStarted"
+	eventCardPlayed     = "cardPlayed"
+	eventDamageDealt    = "damageDealt"
+	eventHealed         = "healed"
+	eventDotApplied     = "dotApplied"
+	eventHotApplied     = "hotApplied"
+	eventDotTicked      = "dotTicked"
+	eventHotTicked      = "hotTicked"
+	eventBuffApplied    = "buffApplied"
+	eventDebuffApplied  = "debuffApplied"
+	eventStatusApplied  = "statusApplied"
+	eventStatusResisted = "statusResisted"
+	eventTurnSkipped    = "turnSkipped"
+	eventEnemyDefeated  = "enemyDefeated"
+	eventPlayerDefeated = "playerDefeated"
+	eventXPGained       = "xpGained"
+	eventLevelUp        = "levelUp"
+	eventLootDropped    = "lootDropped"
+	eventManaRestored   = "manaRestored"
+)
+
+// CombatEvent is one thing that happened during a fight.
+type CombatEvent struct {
+	Round        int              `json:"round"`
+	Type         string           `json:"type"`
+	Source       string           `json:"source,omitempty"`
+	Target       string           `json:"target,omitempty"`
+	Amount       int              `json:"amount,omitempty"`
+	Name         string           `json:"name,omitempty"`         // Card, ability, stat or status involved
+	TargetHealth *int             `json:"targetHealth,omitempty"` // Target's health after the event
+	Hit          *DamageBreakdown `json:"hit,omitempty"`          // How a damageDealt event was rolled
+}
+
+// CombatLog keeps every event of the current encounter.
+type CombatLog struct {
+	Round  int           `json:"round"`
+	Events []CombatEvent `json:"events"`
+}
+
+func newCombatLog() *CombatLog {
+	return &CombatLog{Events: []CombatEvent{}}
+}
+
+// record stamps the event with the current round and appends it.
+func (l *CombatLog) record(event CombatEvent) {
+	event.Round = l.Round
+	l.Events = append(l.Events, event)
+}
+
+// Since returns the events recorded after the first n.
+func (l *CombatLog) Since(n int) []CombatEvent {
+	return append([]CombatEvent{}, l.Events[n:]...)
+}
+
+// healthOf snapshots an entity's health for an event.
+func healthOf(e *Entity) *int {
+	health := e.Health
+	return &health
+}
+
+// narrate renders events as the plain-text summary shown in the combat log.
+func narrate(events []CombatEvent) string {
+	var sb strings.Builder
+	for _, event := range events {
+		sb.WriteString(describeEvent(event))
+	}
+	return sb.String()
+}
+
+func describeEvent(event CombatEvent) string {
+	switch event.Type {
+	case eventCombatStarted:
+		return fmt.Sprintf(" A %s appears!", event.Target)
+	case eventCardPlayed:
+		return fmt.Sprintf(" %s uses %s!", event.Source, event.Name)
+	case eventDamageDealt:
+		if event.Hit != nil {
+			return describeHit(*event.Hit)
+		}
+		return fmt.Sprintf(" %s takes %d damage.", event.Target, event.Amount)
+	case eventHealed:
+		return fmt.Sprintf(" %s heals for %d health.", event.Target, event.Amount)
+	case eventDotApplied:
+		return fmt.Sprintf(" %s is afflicted with damage over time.", event.Target)
+	case eventHotApplied:
+		return fmt.Sprintf(" %s will heal over time.", event.Target)
+	case eventDotTicked:
+		return fmt.Sprintf(" %s takes %d damage.", event.Target, event.Amount)
+	case eventHotTicked:
+		return fmt.Sprintf(" %s heals %d health.", event.Target, event.Amount)
+	case eventBuffApplied:
+		return fmt.Sprintf(" %s's %s is increased.", event.Target, event.Name)
+	case eventDebuffApplied:
+		return fmt.Sprintf(" %s's %s is decreased.", event.Target, event.Name)
+	case eventStatusApplied:
+		return fmt.Sprintf(" %s is affected by %s.", event.Target, event.Name)
+	case eventStatusResisted:
+		return fmt.Sprintf(" %s resists %s.", event.Target, event.Name)
+	case eventTurnSkipped:
+		if event.Name == "notEnoughMana" {
+			return " Not enough mana to cast this spell."
+		}
+		return fmt.Sprintf(" %s is %s and cannot act!", event.Target, statusAdjective(event.Name))
+	case eventEnemyDefeated:
+		return fmt.Sprintf(" %s defeated!", event.Target)
+	case eventPlayerDefeated:
+		return " Player defeated! Game over."
+	case eventXPGained:
+		return fmt.Sprintf(" You gain %d XP.", event.Amount)
+	case eventLootDropped:
+		return fmt.Sprintf(" %s drops %s.", event.Source, event.Name)
+	case eventLevelUp:
+		return fmt.Sprintf(" %s reaches level %d!", event.Target, event.Amount)
+	case eventManaRestored:
+		return fmt.Sprintf(" %s recovers %d mana.", event.Target, event.Amount)
+	}
+	return ""
+}
+
+func statusAdjective(status string) string {
+	switch status {
+	case "freeze":
+		return "frozen"
+	case "stun":
+		return "stunned"
+	}
+	return status
+}
+
+func CombatLogHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatLog == nil {
+		http.Error(w, "No encounter yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.CombatLog)
+}
@@ -2,7 +2,10 @@ module heroes-and-decks
 
 go 1.23.2
 
-require modernc.org/sqlite v1.33.1
+require (
+	golang.org/x/crypto v0.25.0
+	modernc.org/sqlite v1.33.1
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
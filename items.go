@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Equipment slots. A character wears at most one item in each.
+const (
+	slotWeapon  = "weapon"
+	slotArmor   = "armor"
+	slotTrinket = "trinket"
+)
+
+var itemSlots = map[string]bool{
+	slotWeapon:  true,
+	slotArmor:   true,
+	slotTrinket: true,
+}
+
+// StatModifier permanently changes an effective stat while its source is
+// active, using the same rules as a buff.
+type StatModifier struct {
+	Stat     string  `json:"stat,omitempty"`
+	Modifier float64 `json:"modifier,omitempty"`
+	Mode     string  `json:"mode,omitempty"` // "multiply" (default) or "add"
+}
+
+// Item is a piece of equipment. Its modifiers and resistances apply while
+// it is equipped, and its effects are applied when a fight starts.
+type Item struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Slot        string             `json:"slot"`
+	Rarity      string             `json:"rarity"`
+	Value       int                `json:"value"`            // Price in gold
+	Damage      int                `json:"damage,omitempty"` // Added to basic attacks by weapons
+	Modifiers   []StatModifier     `json:"modifiers,omitempty"`
+	Resistances map[string]float64 `json:"resistances,omitempty"`
+	Effects     []Effect           `json:"effects,omitempty"`
+	Description string             `json:"description"`
+}
+
+// ItemCatalog holds every item definition known to the server.
+type ItemCatalog struct {
+	items map[string]Item
+	order []string
+}
+
+var itemCatalog = &ItemCatalog{items: make(map[string]Item)}
+
+// loadItemCatalog reads every item file in dir and replaces the catalog once
+// all of them have been validated.
+func loadItemCatalog(dir string) error {
+	items, err := readDataDir[Item](dir)
+	if err != nil {
+		return err
+	}
+
+	catalog := &ItemCatalog{items: make(map[string]Item)}
+	for _, item := range items {
+		if item.Rarity == "" {
+			item.Rarity = defaultRarity
+		}
+		if err := validateItem(item); err != nil {
+			return err
+		}
+		if _, exists := catalog.items[item.ID]; exists {
+			return fmt.Errorf("duplicate item id %q", item.ID)
+		}
+		catalog.items[item.ID] = item
+		catalog.order = append(catalog.order, item.ID)
+	}
+
+	itemCatalog = catalog
+	return nil
+}
+
+func validateItem(item Item) error {
+	if item.ID == "" {
+		return fmt.Errorf("item %q has no id", item.Name)
+	}
+	if item.Name == "" {
+		return fmt.Errorf("item %q has no name", item.ID)
+	}
+	if !itemSlots[item.Slot] {
+		return fmt.Errorf("item %q has unknown slot %q", item.ID, item.Slot)
+	}
+	if _, ok := rarityWeights[item.Rarity]; !ok {
+		return fmt.Errorf("item %q has unknown rarity %q", item.ID, item.Rarity)
+	}
+	if item.Value < 0 {
+		return fmt.Errorf("item %q has a negative value", item.ID)
+	}
+	if item.Damage != 0 && item.Slot != slotWeapon {
+		return fmt.Errorf("item %q deals damage but is not a weapon", item.ID)
+	}
+	for _, modifier := range item.Modifiers {
+		if err := validateStatModifier(modifier); err != nil {
+			return fmt.Errorf("item %q: %w", item.ID, err)
+		}
+	}
+	for damageType := range item.Resistances {
+		if !damageTypes[damageType] {
+			return fmt.Errorf("item %q resists unknown damage type %q", item.ID, damageType)
+		}
+	}
+	for _, effect := range item.Effects {
+		if err := validateEffect(effect); err != nil {
+			return fmt.Errorf("item %q: %w", item.ID, err)
+		}
+	}
+	return nil
+}
+
+func validateStatModifier(modifier StatModifier) error {
+	// Attack only exists as a combat modifier, not as an effective stat
+	if !buffableStats[modifier.Stat] || modifier.Stat == "attack" {
+		return fmt.Errorf("modifier for unknown stat %q", modifier.Stat)
+	}
+	switch modifier.Mode {
+	case "", buffMultiply, buffAdd:
+	default:
+		return fmt.Errorf("modifier for %s has unknown mode %q", modifier.Stat, modifier.Mode)
+	}
+	return nil
+}
+
+// Get returns the item with the given ID, or nil if there is none.
+func (c *ItemCatalog) Get(id string) *Item {
+	item, ok := c.items[id]
+	if !ok {
+		return nil
+	}
+	return &item
+}
+
+// All returns every item in the order they were defined.
+func (c *ItemCatalog) All() []Item {
+	items := make([]Item, 0, len(c.order))
+	for _, id := range c.order {
+		items = append(items, c.items[id])
+	}
+	return items
+}
+
+// modifierBuffs expresses stat modifiers as permanent buffs.
+func modifierBuffs(modifiers []StatModifier) []Buff {
+	var buffs []Buff
+	for _, modifier := range modifiers {
+		if modifier.Stat == "" {
+			continue
+		}
+		buffs = append(buffs, Buff{Stat: modifier.Stat, Modifier: modifier.Modifier, Mode: modifier.Mode})
+	}
+	return buffs
+}
+
+// equippedItems returns the items the character is wearing, skipping any
+// that are no longer in the catalog.
+func (c *Character) equippedItems() []Item {
+	var items []Item
+	for _, slot := range []string{slotWeapon, slotArmor, slotTrinket} {
+		if item := itemCatalog.Get(c.Equipment[slot]); item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// weaponDamage is the bonus the character's weapon adds to basic attacks.
+func (c *Character) weaponDamage() int {
+	if weapon := itemCatalog.Get(c.Equipment[slotWeapon]); weapon != nil {
+		return weapon.Damage
+	}
+	return 0
+}
+
+// equipStartingGear gives the character the class's starting items, equipped.
+func (c *Character) equipStartingGear(class *Class) {
+	c.Equipment = make(map[string]string)
+	c.Inventory = []string{}
+	for _, id := range class.StartingGear {
+		if item := itemCatalog.Get(id); item != nil {
+			c.Equipment[item.Slot] = item.ID
+		}
+	}
+}
+
+// equip wears an item from the inventory, putting whatever was in its slot back.
+func (c *Character) equip(item *Item) bool {
+	index := -1
+	for i, id := range c.Inventory {
+		if id == item.ID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false
+	}
+
+	c.Inventory = append(c.Inventory[:index], c.Inventory[index+1:]...)
+	if current, ok := c.Equipment[item.Slot]; ok {
+		c.Inventory = append(c.Inventory, current)
+	}
+	if c.Equipment == nil {
+		c.Equipment = make(map[string]string)
+	}
+	c.Equipment[item.Slot] = item.ID
+	return true
+}
+
+// unequip moves the item in a slot back to the inventory.
+func (c *Character) unequip(slot string) bool {
+	id, ok := c.Equipment[slot]
+	if !ok {
+		return false
+	}
+	delete(c.Equipment, slot)
+	c.Inventory = append(c.Inventory, id)
+	return true
+}
+
+// applyGearEffects applies the effects of the character's equipment at the
+// start of a fight.
+func applyGearEffects(player *Character, enemy *Enemy, log *CombatLog) {
+	for _, item := range player.equippedItems() {
+		if len(item.Effects) > 0 {
+			applyEffects(item.Name, item.Effects, false, player, enemy, log)
+		}
+	}
+}
+
+// weaponItem returns the enemy's weapon, or nil if it has none.
+func (e *Enemy) weaponItem() *Item {
+	return itemCatalog.Get(e.Weapon)
+}
+
+func ItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemCatalog.All())
+}
+
+// InventoryHandler lists what the character is wearing and carrying.
+func InventoryHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeInventory(w, &s.Player)
+}
+
+func EquipHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't change equipment during combat", http.StatusConflict)
+		return
+	}
+
+	var equipData struct {
+		ItemID string `json:"itemId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&equipData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	item := itemCatalog.Get(equipData.ItemID)
+	if item == nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if !s.Player.equip(item) {
+		http.Error(w, "Item not in inventory", http.StatusBadRequest)
+		return
+	}
+
+	writeInventory(w, &s.Player)
+}
+
+func UnequipHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't change equipment during combat", http.StatusConflict)
+		return
+	}
+
+	var unequipData struct {
+		Slot string `json:"slot"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&unequipData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if !itemSlots[unequipData.Slot] {
+		http.Error(w, "Invalid slot", http.StatusBadRequest)
+		return
+	}
+	if !s.Player.unequip(unequipData.Slot) {
+		http.Error(w, "Nothing equipped in that slot", http.StatusBadRequest)
+		return
+	}
+
+	writeInventory(w, &s.Player)
+}
+
+func writeInventory(w http.ResponseWriter, c *Character) {
+	equipment := map[string]*Item{}
+	for slot, id := range c.Equipment {
+		equipment[slot] = itemCatalog.Get(id)
+	}
+	inventory := []*Item{}
+	for _, id := range c.Inventory {
+		if item := itemCatalog.Get(id); item != nil {
+			inventory = append(inventory, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"equipment": equipment,
+		"inventory": inventory,
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
@@ -25,27 +24,33 @@ type Card struct {
 	ID       int      `json:"id"`
 	Name     string   `json:"name"`
 	ManaCost int      `json:"manaCost"`
-	Type     string   `json:"type"`    // e.g., "spell", "attack", "minion"
-	Effects  []Effect `json:"effects"` // List of effects this card has
+	Type     string   `json:"type"`              // e.g., "spell", "attack", "minion"
+	Class    string   `json:"class,omitempty"`   // Only this class can use the card; empty for neutral cards
+	Rarity   string   `json:"rarity"`            // "common", "uncommon" or "rare"
+	Exhaust  bool     `json:"exhaust,omitempty"` // Removed from the fight once played
+	Effects  []Effect `json:"effects"`           // List of effects this card has
+
+	Upgraded *CardUpgrade `json:"upgraded,omitempty"` // The card's upgraded form, if it has one
 }
 
 type Enemy struct {
 	Entity
-	Name             string `json:"name"`
-	Health           int    `json:"health"`
-	MaxHealth        int    `json:"maxHealth"`
+	ID               string `json:"id"`
 	Strength         int    `json:"strength"`
 	Dexterity        int    `json:"dexterity"`
 	Intelligence     int    `json:"intelligence"`
 	Armor            int    `json:"armor"`
-	Weapon           string `json:"weapon"`
+	Weapon           string `json:"weapon"` // Item ID
 	Level            int    `json:"level"`
 	ExperienceReward int    `json:"experienceReward"` // Reward given upon defeat
 
-	ActiveDoTs   []DoT
-	ActiveHoTs   []HoT
-	ActiveBuffs  []Buff
-	ActiveStatus []StatusEffect
+	Resistances map[string]float64 `json:"resistances,omitempty"` // Damage type -> fraction resisted
+
+	Abilities []Card      `json:"abilities"`        // Actions the enemy can take on its turn
+	AI        string      `json:"ai,omitempty"`     // Name of the policy that picks its actions
+	LootTable []LootEntry `json:"lootTable"`        // Weighted drops rolled on defeat
+	Intent    *Intent     `json:"intent,omitempty"` // Telegraphed action for its next turn
+	Turn      int         `json:"-"`                // Turns taken so far this fight
 }
 
 type Stats struct {
@@ -59,90 +64,148 @@ type Stats struct {
 	Luck         int `json:"luck"`
 }
 
+// Entity is the combat state shared by characters and enemies. Both embed it,
+// so effects applied through Target land on the same fields that are ticked
+// at the start of each round.
 type Entity struct {
-	Name         string
-	Health       int
-	MaxHealth    int
-	ActiveDoTs   []DoT
-	ActiveHoTs   []HoT
-	ActiveBuffs  []Buff
-	ActiveStatus []StatusEffect
-	// Add other necessary fields
+	Name         string         `json:"name"`
+	Health       int            `json:"health"`
+	MaxHealth    int            `json:"maxHealth"`
+	ActiveDoTs   []DoT          `json:"activeDoTs,omitempty"`
+	ActiveHoTs   []HoT          `json:"activeHoTs,omitempty"`
+	ActiveBuffs  []Buff         `json:"activeBuffs,omitempty"`
+	ActiveStatus []StatusEffect `json:"activeStatus,omitempty"`
 }
 
 type Character struct {
 	Entity
-	Name      string `json:"name"`
-	Class     string `json:"class"`
-	Race      string `json:"race"`
-	Level     int    `json:"level"`
-	XP        int    `json:"xp"`
-	Health    int    `json:"health"`
-	MaxHealth int    `json:"maxHealth"`
-	Mana      int    `json:"mana"`
-	MaxMana   int    `json:"maxMana"`
-	Gold      int    `json:"gold"`
-	Armor     string `json:"armor"`
-	Weapon    string `json:"weapon"`
-	Stats     Stats  `json:"stats"`
-
-	ActiveDoTs   []DoT
-	ActiveHoTs   []HoT
-	ActiveBuffs  []Buff
-	ActiveStatus []StatusEffect
+	Class   string         `json:"class"`
+	Race    string         `json:"race"`
+	Level   int            `json:"level"`
+	XP      int            `json:"xp"`
+	Mana    int            `json:"mana"`
+	MaxMana int            `json:"maxMana"`
+	Gold    int            `json:"gold"`
+	Stats   Stats          `json:"stats"`
+	Deck    []CardInstance `json:"deck"` // Copies of cards the character brings into combat
+
+	Equipment map[string]string `json:"equipment"` // Item ID worn in each slot
+	Inventory []string          `json:"inventory"` // Item IDs carried but not worn
+
+	StatPoints int     `json:"statPoints"`          // Unspent points from level-ups
+	CardPicks  [][]int `json:"cardPicks,omitempty"` // Pending level-up picks, each a choice of card IDs
+
+	BoostOffers []StatBoostOffer `json:"boostOffers,omitempty"` // Unclaimed stat boosts
+
+	Rewards      []Reward `json:"rewards,omitempty"` // Spoils waiting to be claimed
+	Shop         *Shop    `json:"shop,omitempty"`    // Stock of the merchant the character is visiting
+	CardRemovals int      `json:"cardRemovals"`      // Cards removed at the merchant so far, raising the price
+
+	Run *Run `json:"run,omitempty"` // The dungeon map and the character's place on it
 }
 
 type DoT struct {
-	Amount   int
-	Duration int
+	Amount   int `json:"amount"`
+	Duration int `json:"duration"`
 }
 
 type HoT struct {
-	Amount   int
-	Duration int
+	Amount   int `json:"amount"`
+	Duration int `json:"duration"`
 }
 
 type Buff struct {
-	Stat     string
-	Modifier float64
-	Duration int
+	Stat     string  `json:"stat"`
+	Modifier float64 `json:"modifier"`
+	Mode     string  `json:"mode"` // "multiply" or "add"
+	Duration int     `json:"duration"`
 }
 
 type StatusEffect struct {
-	EffectName string
-	Chance     float64
-	Duration   int
+	EffectName string  `json:"effectName"`
+	Chance     float64 `json:"chance"`
+	Duration   int     `json:"duration"`
 }
 
 type Target interface {
 	ApplyDoT(amount int, duration int)
 	ApplyHoT(amount int, duration int)
-	ApplyBuff(stat string, modifier float64, duration int)
+	ApplyBuff(stat string, modifier float64, mode string, duration int)
 	ApplyStatusEffect(effectName string, chance float64, duration int)
 	ReceiveDamage(amount int) int
 	GetName() string
 	GetHealth() int
 	SetHealth(int)
 	GetMaxHealth() int
+	Combatant() *Entity
+	EffectiveStat(name string) int
+	Resistance(damageType string) float64
 }
 
-var currentEnemy *Enemy
-var player Character
 var db *sql.DB
 
 func main() {
+	// Load the card catalog before serving any requests
+	if err := loadCardCatalog("./data/cards"); err != nil {
+		log.Fatal("Failed to load cards: ", err)
+	}
+	if err := loadItemCatalog("./data/items"); err != nil {
+		log.Fatal("Failed to load items: ", err)
+	}
+	if err := loadBestiary("./data/enemies"); err != nil {
+		log.Fatal("Failed to load enemies: ", err)
+	}
+	if err := loadRaces("./data/races"); err != nil {
+		log.Fatal("Failed to load races: ", err)
+	}
+	if err := loadClasses("./data/classes"); err != nil {
+		log.Fatal("Failed to load classes: ", err)
+	}
+	if err := migrate(db); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	}
+
 	fs := http.FileServer(http.Dir("./client"))
 	http.Handle("/", fs)
 
 	// Add CORS middleware to handle the preflight requests
 	http.HandleFunc("/create-character", withCORS(CreateCharacterHandler))
-	http.HandleFunc("/character", withCORS(CharacterHandler))
-	http.HandleFunc("/apply-stat-boost", withCORS(ApplyStatBoostHandler))
-	http.HandleFunc("/randomize-card", withCORS(RandomizeCard))
-	http.HandleFunc("/start-combat", withCORS(StartCombatHandler))
-	http.HandleFunc("/use-card", withCORS(UseCardHandler))
-	http.HandleFunc("/save-progress", withCORS(SaveProgressHandler))
-	http.HandleFunc("/load-progress", withCORS(LoadProgressHandler))
+	http.HandleFunc("/character", withCORS(withSession(CharacterHandler)))
+	http.HandleFunc("/stat-boosts", withCORS(withSession(StatBoostsHandler)))
+	http.HandleFunc("/stat-boosts/claim", withCORS(withSession(ClaimStatBoostHandler)))
+	http.HandleFunc("/progression", withCORS(withSession(ProgressionHandler)))
+	http.HandleFunc("/spend-stat-points", withCORS(withSession(SpendStatPointsHandler)))
+	http.HandleFunc("/pick-card", withCORS(withSession(PickCardHandler)))
+	http.HandleFunc("/start-combat", withCORS(withSession(StartCombatHandler)))
+	http.HandleFunc("/deck", withCORS(withSession(DeckHandler)))
+	http.HandleFunc("/combat-log", withCORS(withSession(CombatLogHandler)))
+	http.HandleFunc("/cards", withCORS(CardsHandler))
+	http.HandleFunc("/cards/{id}", withCORS(CardHandler))
+	http.HandleFunc("/bestiary", withCORS(BestiaryHandler))
+	http.HandleFunc("/items", withCORS(ItemsHandler))
+	http.HandleFunc("/inventory", withCORS(withSession(InventoryHandler)))
+	http.HandleFunc("/equip", withCORS(withSession(EquipHandler)))
+	http.HandleFunc("/unequip", withCORS(withSession(UnequipHandler)))
+	http.HandleFunc("/rewards", withCORS(withSession(RewardsHandler)))
+	http.HandleFunc("/rewards/claim", withCORS(withSession(ClaimRewardHandler)))
+	http.HandleFunc("/shop", withCORS(withSession(ShopHandler)))
+	http.HandleFunc("/shop/buy", withCORS(withSession(BuyHandler)))
+	http.HandleFunc("/shop/remove-card", withCORS(withSession(RemoveCardHandler)))
+	http.HandleFunc("/shop/upgrade", withCORS(withSession(UpgradeCardHandler)))
+	http.HandleFunc("/map", withCORS(withSession(MapHandler)))
+	http.HandleFunc("/map/choose", withCORS(withSession(ChooseNodeHandler)))
+	http.HandleFunc("/map/new-run", withCORS(withSession(NewRunHandler)))
+	http.HandleFunc("/rest", withCORS(withSession(RestHandler)))
+	http.HandleFunc("/races", withCORS(RacesHandler))
+	http.HandleFunc("/classes", withCORS(ClassesHandler))
+	http.HandleFunc("/schema/{name}", withCORS(SchemaHandler))
+	http.HandleFunc("/register", withCORS(RegisterHandler))
+	http.HandleFunc("/login", withCORS(LoginHandler))
+	http.HandleFunc("/logout", withCORS(withSession(LogoutHandler)))
+	http.HandleFunc("/save-progress", withCORS(withSession(SaveProgressHandler)))
+	http.HandleFunc("/load-progress", withCORS(withSession(LoadProgressHandler)))
+	http.HandleFunc("/saves", withCORS(withSession(ListSavesHandler)))
+	http.HandleFunc("/saves/restore", withCORS(withSession(RestoreSaveHandler)))
 
 	fmt.Println("Server running on port 8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -153,23 +216,25 @@ func init() {
 	rand.NewSource(time.Now().UnixNano())
 	// Open the database connection
 	var err error
-	db, err = sql.Open("sqlite", "./game.db")
+	db, err = openDatabase("./game.db")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Create the players table if it doesn't exist
-	createTableSQL := `
-    CREATE TABLE IF NOT EXISTS players (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL UNIQUE,
-        data TEXT NOT NULL
-    );
-    `
-	_, err = db.Exec(createTableSQL)
+	characterRepo = &CharacterRepository{db: db}
+}
+
+// openDatabase opens the SQLite database at path. Requests save and load at
+// the same time, so writers wait for each other instead of failing with
+// SQLITE_BUSY, readers don't block on them, and the pool keeps a single
+// connection so transactions never compete for the write lock.
+func openDatabase(path string) (*sql.DB, error) {
+	database, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
-		log.Fatal("Failed to create table:", err)
+		return nil, err
 	}
+	database.SetMaxOpenConns(1)
+	return database, nil
 }
 
 // CORS middleware
@@ -178,7 +243,8 @@ func withCORS(next http.HandlerFunc) http.HandlerFunc {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+sessionHeader)
+		w.Header().Set("Access-Control-Expose-Headers", sessionHeader)
 
 		// Handle preflight OPTIONS request
 		if r.Method == http.MethodOptions {
@@ -191,18 +257,16 @@ func withCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func (e Entity) ApplyDoT(amount int, duration int) {
+func (e *Entity) ApplyDoT(amount int, duration int) {
 	e.ActiveDoTs = append(e.ActiveDoTs, DoT{Amount: amount, Duration: duration})
-	currentEnemy.ActiveDoTs = append(currentEnemy.ActiveDoTs, DoT{Amount: amount, Duration: duration})
 }
 
 func (e *Entity) ApplyHoT(amount int, duration int) {
 	e.ActiveHoTs = append(e.ActiveHoTs, HoT{Amount: amount, Duration: duration})
-
 }
 
-func (e *Entity) ApplyBuff(stat string, modifier float64, duration int) {
-	e.ActiveBuffs = append(e.ActiveBuffs, Buff{Stat: stat, Modifier: modifier, Duration: duration})
+func (e *Entity) ApplyBuff(stat string, modifier float64, mode string, duration int) {
+	e.addBuff(Buff{Stat: stat, Modifier: modifier, Mode: mode, Duration: duration})
 }
 
 func (e *Entity) ApplyStatusEffect(effectName string, chance float64, duration int) {
@@ -216,139 +280,81 @@ func (e *Entity) ReceiveDamage(amount int) int {
 	}
 	return amount
 }
-func (c *Character) ApplyDoT(amount int, duration int) {
-	c.ActiveDoTs = append(c.ActiveDoTs, DoT{Amount: amount, Duration: duration})
-}
-
-func (c *Character) ApplyHoT(amount int, duration int) {
-	c.ActiveHoTs = append(c.ActiveHoTs, HoT{Amount: amount, Duration: duration})
-}
-
-func (c *Character) ApplyBuff(stat string, modifier float64, duration int) {
-	c.ActiveBuffs = append(c.ActiveBuffs, Buff{Stat: stat, Modifier: modifier, Duration: duration})
-}
-
-func (c *Character) ApplyStatusEffect(effectName string, chance float64, duration int) {
-	c.ActiveStatus = append(c.ActiveStatus, StatusEffect{EffectName: effectName, Chance: chance, Duration: duration})
-}
-
-func (c *Character) ReceiveDamage(amount int) int {
-	c.Health -= amount
-	if c.Health < 0 {
-		c.Health = 0
-	}
-	return amount
-}
 
-func (c *Character) GetName() string {
-	return c.Name
-}
-
-func (c *Character) GetHealth() int {
-	return c.Health
-}
-
-func (c *Character) SetHealth(h int) {
-	c.Health = h
-}
-
-func (c *Character) GetMaxHealth() int {
-	return c.MaxHealth
-}
-
-func (e *Enemy) ApplyDoT(amount int, duration int) {
-	e.ActiveDoTs = append(e.ActiveDoTs, DoT{Amount: amount, Duration: duration})
-}
-
-func (e *Enemy) ApplyHoT(amount int, duration int) {
-	e.ActiveHoTs = append(e.ActiveHoTs, HoT{Amount: amount, Duration: duration})
-}
-
-func (e *Enemy) ApplyBuff(stat string, modifier float64, duration int) {
-	e.ActiveBuffs = append(e.ActiveBuffs, Buff{Stat: stat, Modifier: modifier, Duration: duration})
-}
-
-func (e *Enemy) ApplyStatusEffect(effectName string, chance float64, duration int) {
-	e.ActiveStatus = append(e.ActiveStatus, StatusEffect{EffectName: effectName, Chance: chance, Duration: duration})
-}
-
-func (e *Enemy) ReceiveDamage(amount int) int {
-	e.Health -= amount
-	if e.Health < 0 {
-		e.Health = 0
-	}
-	return amount
-}
-
-func (e *Enemy) GetName() string {
+func (e *Entity) GetName() string {
 	return e.Name
 }
 
-func (e *Enemy) GetHealth() int {
+func (e *Entity) GetHealth() int {
 	return e.Health
 }
 
-func (e *Enemy) SetHealth(h int) {
+func (e *Entity) SetHealth(h int) {
 	e.Health = h
 }
 
-func (e *Enemy) GetMaxHealth() int {
+func (e *Entity) GetMaxHealth() int {
 	return e.MaxHealth
 }
 
-func SavePlayerToDB(p Character) error {
-	data, err := json.Marshal(p)
-	if err != nil {
-		return err
-	}
-
-	// Insert or replace the player data
-	query := `
-    INSERT INTO players (name, data) VALUES (?, ?)
-    ON CONFLICT(name) DO UPDATE SET data=excluded.data;
-    `
-	_, err = db.Exec(query, p.Name, string(data))
-	return err
+func (e *Entity) Combatant() *Entity {
+	return e
 }
 
-func LoadPlayerFromDB(name string) (Character, error) {
-	var p Character
-	query := `SELECT data FROM players WHERE name = ?;`
-	row := db.QueryRow(query, name)
-
-	var data string
-	err := row.Scan(&data)
-	if err != nil {
-		return p, err
-	}
-
-	err = json.Unmarshal([]byte(data), &p)
-	return p, err
+// clearCombatEffects drops everything applied during a fight once it ends.
+func (e *Entity) clearCombatEffects() {
+	e.ActiveDoTs = nil
+	e.ActiveHoTs = nil
+	e.ActiveBuffs = nil
+	e.ActiveStatus = nil
 }
 
-func SaveProgressHandler(w http.ResponseWriter, r *http.Request) {
+// SaveProgressHandler saves the session's character as the server has it
+// into one of the numbered slots. The client only picks the slot; nothing
+// else it sends ends up in the save.
+func SaveProgressHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	if r.Method != "POST" {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// Read the request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Can't read body", http.StatusBadRequest)
+	if s.AccountID == 0 {
+		http.Error(w, "Log in to save", http.StatusUnauthorized)
+		return
+	}
+	if s.Player.Name == "" {
+		http.Error(w, "No character to save", http.StatusBadRequest)
+		return
+	}
+	// Mid-fight state like the piles and buffs isn't part of a save
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't save during combat", http.StatusConflict)
 		return
 	}
 
-	// Unmarshal the request body into a Character struct
-	var receivedPlayer Character
-	err = json.Unmarshal(body, &receivedPlayer)
+	var saveData struct {
+		Slot int `json:"slot"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&saveData)
 	if err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	if saveData.Slot < 1 || saveData.Slot > saveSlots {
+		http.Error(w, fmt.Sprintf("Slot must be 1 to %d", saveSlots), http.StatusBadRequest)
+		return
+	}
 
 	// Save the player data to the database
-	err = SavePlayerToDB(receivedPlayer)
+	err = claimCharacter(s)
+	if err == errCharacterExists {
+		http.Error(w, "You already have a character with that name", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error saving progress", http.StatusInternalServerError)
+		return
+	}
+	err = characterRepo.Save(s.AccountID, s.Player, saveData.Slot, fmt.Sprintf("Slot %d", saveData.Slot))
 	if err != nil {
 		http.Error(w, "Error saving progress", http.StatusInternalServerError)
 		return
@@ -358,11 +364,15 @@ func SaveProgressHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Progress saved successfully"))
 }
 
-func LoadProgressHandler(w http.ResponseWriter, r *http.Request) {
+func LoadProgressHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	if r.Method != "GET" && r.Method != "POST" {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
+	if s.AccountID == 0 {
+		http.Error(w, "Log in to load", http.StatusUnauthorized)
+		return
+	}
 
 	// For simplicity, we'll accept the player name in the request body for POST method
 	var requestData struct {
@@ -385,8 +395,8 @@ func LoadProgressHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Load the player data from the database
-	loadedPlayer, err := LoadPlayerFromDB(requestData.Name)
+	// Load the player data from the database; only the account's own saves are visible
+	loadedPlayer, err := characterRepo.Load(s.AccountID, requestData.Name)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Player not found", http.StatusNotFound)
@@ -396,12 +406,12 @@ func LoadProgressHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update the global player variable
-	player = loadedPlayer
+	// Bind the loaded character to the caller's session
+	resumeCharacter(s, loadedPlayer)
 
 	// Send the player data back to the client
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(player)
+	json.NewEncoder(w).Encode(s.Player)
 }
 
 // Modify the CreateCharacterHandler to accept input
@@ -411,139 +421,107 @@ func CreateCharacterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read the request body
-	body, err := io.ReadAll(r.Body)
+	// The client only picks the name, race and class; everything else is
+	// worked out here
+	var characterData struct {
+		Name  string `json:"name"`
+		Race  string `json:"race"`
+		Class string `json:"class"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&characterData)
 	if err != nil {
-		http.Error(w, "Can't read body", http.StatusBadRequest)
+		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	// Unmarshal the request body into a character struct
-	var newCharacter Character
-	err = json.Unmarshal(body, &newCharacter)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	characterData.Name = strings.TrimSpace(characterData.Name)
+	if characterData.Name == "" {
+		http.Error(w, "Player name is required", http.StatusBadRequest)
 		return
 	}
 
-	// Assign stats based on race and class
-	newCharacter = calculateStats(newCharacter)
+	// Only races and classes defined in the data files can be created
+	race := raceCatalog.Get(characterData.Race)
+	if race == nil {
+		http.Error(w, fmt.Sprintf("Unknown race %q", characterData.Race), http.StatusBadRequest)
+		return
+	}
+	class := classCatalog.Get(characterData.Class)
+	if class == nil {
+		http.Error(w, fmt.Sprintf("Unknown class %q", characterData.Class), http.StatusBadRequest)
+		return
+	}
 
-	player = newCharacter
+	// Assign stats based on race and class
+	newCharacter := calculateStats(Character{Entity: Entity{Name: characterData.Name}}, race, class)
 
-	// Save the new character to the database
-	err = SavePlayerToDB(player)
+	// Bind the new character to the caller's session
+	s, err := sessions.Issue(w, r)
 	if err != nil {
-		http.Error(w, "Error saving new character", http.StatusInternalServerError)
+		http.Error(w, "Error creating session", http.StatusInternalServerError)
 		return
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Save the new character to the account, if the caller is logged in
+	if s.AccountID != 0 {
+		err = characterRepo.Create(s.AccountID, newCharacter)
+		if err == errCharacterExists {
+			http.Error(w, "You already have a character with that name", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error saving new character", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.Player = newCharacter
+	s.Claimed = s.AccountID != 0
+	s.Enemy = nil
+	s.CombatDeck = nil
 
 	// Set default values and initialize the character
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(player)
+	json.NewEncoder(w).Encode(s.Player)
 }
 
-func calculateStats(character Character) Character {
-	var stats Stats
-
-	// Base stats for each race, total points for each race = 80
-	switch character.Race {
-	case "Human":
-		stats = Stats{Strength: 10, Dexterity: 10, Intelligence: 10, Endurance: 10, Perception: 10, Wisdom: 10, Agility: 10, Luck: 10}
-	case "Elf":
-		stats = Stats{Strength: 8, Dexterity: 14, Intelligence: 12, Endurance: 8, Perception: 10, Wisdom: 10, Agility: 14, Luck: 4}
-	case "Dwarf":
-		stats = Stats{Strength: 14, Dexterity: 8, Intelligence: 8, Endurance: 14, Perception: 8, Wisdom: 10, Agility: 6, Luck: 12}
-	case "Orc":
-		stats = Stats{Strength: 16, Dexterity: 8, Intelligence: 6, Endurance: 14, Perception: 8, Wisdom: 6, Agility: 10, Luck: 12}
-	case "Gnome":
-		stats = Stats{Strength: 6, Dexterity: 10, Intelligence: 14, Endurance: 8, Perception: 14, Wisdom: 10, Agility: 10, Luck: 8}
-	}
-
-	// Adjust stats and add base gear based on class
-	switch character.Class {
-	case "Warrior":
-		stats.Strength += 5
-		stats.Endurance += 3
-		character.Armor = "Wooden Barrel Plate"
-		character.Weapon = "Training Wooden Sword"
-	case "Mage":
-		stats.Intelligence += 5
-		stats.Luck += 3
-		character.Armor = "Old Teared Cloak"
-		character.Weapon = "Stale Tree Branch"
-	case "Rogue":
-		stats.Dexterity += 5
-		stats.Agility += 3
-		character.Armor = "Faded Leather Jacket"
-		character.Weapon = "Splintered Butter Knife"
+// calculateStats sets up a new character of the given race and class.
+func calculateStats(character Character, race *Race, class *Class) Character {
+	// Race base stats plus the class bonuses
+	stats := race.BaseStats
+	for _, name := range statNames {
+		*statPointer(&stats, name) += *statPointer(&class.StatBonuses, name)
 	}
+	character.Race = race.Name
+	character.Class = class.Name
+	character.equipStartingGear(class)
 
 	// Set initial health and mana values and their maximums
 	character.Level = 1
 	character.XP = 0
 	character.Gold = 100
 	character.Stats = stats
-	character.MaxHealth = stats.Endurance * 10
-	character.MaxMana = stats.Intelligence * 5
+	character.MaxHealth = maxHealthFor(&character)
+	character.MaxMana = maxManaFor(&character)
 	character.Health = character.MaxHealth // Start at full health
 	character.Mana = character.MaxMana     // Start at full mana
+	character.Deck = starterDeck(class.Name)
+
+	// Rewards are only ever granted by the server
+	character.StatPoints = 0
+	character.CardPicks = nil
+	character.BoostOffers = nil
+	character.Shop = nil
+	character.Rewards = nil
+	character.CardRemovals = 0
+	character.clearCombatEffects()
+	grantStatBoostOffers(&character, startingStatBoostOffers)
+	character.Run = newRun()
 
 	return character
 }
 
-// Apply stat boost handler
-func ApplyStatBoostHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Can't read body", http.StatusBadRequest)
-		return
-	}
-
-	// Parse the request body
-	var boostData struct {
-		Card       int    `json:"card"`
-		ChosenStat string `json:"chosenStat"`
-	}
-	err = json.Unmarshal(body, &boostData)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
-		return
-	}
-
-	// Validate the card value (should be between 1 and 4)
-	if boostData.Card < 1 || boostData.Card > 4 {
-		http.Error(w, "Invalid card value", http.StatusBadRequest)
-		return
-	}
-
-	// Apply the stat boost based on the selected card and stat
-	applyStatBoost(&player, boostData.ChosenStat, boostData.Card)
-
-	// Respond with the updated character data
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(player)
-}
-
-func RandomizeCard(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Randomize a card value between 1 and 4
-	randomCardValue := rand.Intn(4) + 1
-
-	// Send the randomized card value to the frontend
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(randomCardValue)
-}
-
 // Apply stat boost based on the selected stat and card value
 func applyStatBoost(character *Character, statName string, boost int) {
 	statName = strings.ToLower(statName) // Ensure case-insensitive comparison
@@ -559,7 +537,7 @@ func applyStatBoost(character *Character, statName string, boost int) {
 		character.Stats.Intelligence += boost
 		// Check if Mana should be restored to new MaxMana only if it was already at MaxMana
 		wasAtMaxMana := character.Mana == character.MaxMana
-		character.MaxMana = character.Stats.Intelligence * 5 // Recalculate MaxMana
+		character.MaxMana = maxManaFor(character) // Recalculate MaxMana
 		if wasAtMaxMana {
 			character.Mana = character.MaxMana // Restore to new max only if already at max
 		}
@@ -567,7 +545,7 @@ func applyStatBoost(character *Character, statName string, boost int) {
 		character.Stats.Endurance += boost
 		// Check if Health should be restored to new MaxHealth only if it was already at MaxHealth
 		wasAtMaxHealth := character.Health == character.MaxHealth
-		character.MaxHealth = character.Stats.Endurance * 10 // Recalculate MaxHealth
+		character.MaxHealth = maxHealthFor(character) // Recalculate MaxHealth
 		if wasAtMaxHealth {
 			character.Health = character.MaxHealth // Restore to new max only if already at max
 		}
@@ -587,212 +565,198 @@ func applyStatBoost(character *Character, statName string, boost int) {
 	fmt.Printf("Updated MaxHealth: %d, Health: %d, MaxMana: %d, Mana: %d\n", character.MaxHealth, character.Health, character.MaxMana, character.Mana)
 }
 
-func CharacterHandler(w http.ResponseWriter, r *http.Request) {
+func CharacterHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(player)
+	json.NewEncoder(w).Encode(s.Player)
 }
 
-func CombatRound(player *Character, enemy *Enemy, action string, card *Card) map[string]interface{} {
-	var result string
-	var combatOver bool
-
-	// Create Entity instances for player and enemy
-	playerEntity := &Entity{
-		Name:         player.Name,
-		Health:       player.Health,
-		MaxHealth:    player.MaxHealth,
-		ActiveDoTs:   player.Entity.ActiveDoTs,
-		ActiveHoTs:   player.ActiveHoTs,
-		ActiveBuffs:  player.ActiveBuffs,
-		ActiveStatus: player.ActiveStatus,
-	}
+// CombatRound plays one round and records what happened in the log. The
+// caller builds the response from the events recorded since the round began.
+// It reports whether the card was cast, so the caller only spends it then.
+func CombatRound(player *Character, enemy *Enemy, action string, card *Card, log *CombatLog) (cast bool) {
+	// Every round's events are stamped with its number
+	log.Round++
 
-	enemyEntity := &Entity{
-		Name:         enemy.Name,
-		Health:       enemy.Health,
-		MaxHealth:    enemy.MaxHealth,
-		ActiveDoTs:   enemy.ActiveDoTs,
-		ActiveHoTs:   enemy.ActiveHoTs,
-		ActiveBuffs:  enemy.ActiveBuffs,
-		ActiveStatus: enemy.ActiveStatus,
-	}
+	// Both sides tick the effects stored on their own Entity
+	playerEntity := &player.Entity
+	enemyEntity := &enemy.Entity
 
 	// Process ongoing effects for the player
-	playerResult := processOngoingEffects(playerEntity)
-	if playerResult != "" {
-		result += playerResult
-	}
-
-	// Update player's effects
-	player.ActiveDoTs = playerEntity.ActiveDoTs
-	player.ActiveHoTs = playerEntity.ActiveHoTs
-	player.ActiveBuffs = playerEntity.ActiveBuffs
-	player.ActiveStatus = playerEntity.ActiveStatus
-	player.Health = playerEntity.Health
+	processOngoingEffects(playerEntity, log)
 
 	// Check if the player is still alive after processing effects
 	if player.Health <= 0 {
-		result += " Player defeated by ongoing effects! Game over."
-		combatOver = true
-		return map[string]interface{}{
-			"result":     result,
-			"playerHP":   player.Health,
-			"enemyHP":    enemy.Health,
-			"playerMana": player.Mana,
-			"combatOver": combatOver,
-		}
+		log.record(CombatEvent{Type: eventPlayerDefeated, Target: player.Name, TargetHealth: healthOf(playerEntity)})
+		return false
 	}
 
 	// Process ongoing effects for the enemy
-	enemyResult := processOngoingEffects(enemyEntity)
-	if enemyResult != "" {
-		result += enemyResult
-	}
-
-	// Update enemy's effects
-	enemy.ActiveDoTs = enemyEntity.ActiveDoTs
-	enemy.ActiveHoTs = enemyEntity.ActiveHoTs
-	enemy.ActiveBuffs = enemyEntity.ActiveBuffs
-	enemy.ActiveStatus = enemyEntity.ActiveStatus
-	enemy.Health = enemyEntity.Health
+	processOngoingEffects(enemyEntity, log)
 
 	// Check if the enemy is still alive after processing effects
 	if enemy.Health <= 0 {
-		result += fmt.Sprintf(" %s defeated by ongoing effects! You gain %d XP.", enemy.Name, enemy.ExperienceReward)
-		player.XP += enemy.ExperienceReward
-		currentEnemy = nil // Reset for new encounter
-		combatOver = true
-		return map[string]interface{}{
-			"result":     result,
-			"playerHP":   player.Health,
-			"enemyHP":    enemy.Health,
-			"playerMana": player.Mana,
-			"combatOver": combatOver,
-		}
+		defeatEnemy(player, enemy, log)
+		return false
 	}
 
 	// Handle player's action
-	if action == "attack" {
+	if status := stunningStatus(playerEntity); status != "" {
+		log.record(CombatEvent{Type: eventTurnSkipped, Target: player.Name, Name: status, TargetHealth: healthOf(playerEntity)})
+	} else if action == "attack" {
 		// Basic Attack
-		playerAttack := player.Stats.Strength * 2 // Example strength-based attack
-		enemy.Health -= playerAttack
-		result += fmt.Sprintf(" Player attacks %s for %d damage!", enemy.Name, playerAttack)
+		playerAttack := player.modifyStat("attack", player.EffectiveStat("strength")*2+player.weaponDamage()) // Strength and weapon based attack
+		hit := resolveDamage(player, enemy, playerAttack, damagePhysical, true)
+		log.record(CombatEvent{Type: eventDamageDealt, Source: player.Name, Target: enemy.Name, Amount: hit.Final, Name: "Attack", TargetHealth: healthOf(enemyEntity), Hit: &hit})
 	} else if action == "castSpell" && card != nil {
 		// Spell Casting
 		if player.Mana >= card.ManaCost { // Check if player has enough mana
 			player.Mana -= card.ManaCost
 			// Apply the card's effects
-			cardResult := applyCardEffects(card, player, enemy)
-			result += cardResult
+			applyCardEffects(card, player, enemy, log)
+			cast = true
 		} else {
-			result += " Not enough mana to cast this spell."
+			log.record(CombatEvent{Type: eventTurnSkipped, Target: player.Name, Name: "notEnoughMana", TargetHealth: healthOf(playerEntity)})
 		}
 	}
+	playerEntity.tickStatuses()
+
+	// A card's self-damage can finish the player off, even as it kills the enemy
+	if player.Health <= 0 {
+		log.record(CombatEvent{Type: eventPlayerDefeated, Target: player.Name, TargetHealth: healthOf(playerEntity)})
+		return cast
+	}
 
 	// Check if enemy is defeated after player's action
 	if enemy.Health <= 0 {
-		result += fmt.Sprintf(" %s defeated! You gain %d XP.", enemy.Name, enemy.ExperienceReward)
-		player.XP += enemy.ExperienceReward
-		currentEnemy = nil // Reset for new encounter
-		combatOver = true
-		return map[string]interface{}{
-			"result":     result,
-			"playerHP":   player.Health,
-			"enemyHP":    enemy.Health,
-			"playerMana": player.Mana,
-			"combatOver": combatOver,
-		}
+		defeatEnemy(player, enemy, log)
+		return cast
 	}
 
 	// Enemy's turn to attack if still alive and combat is not over
-	combatOver = player.Health <= 0 || enemy.Health <= 0
-	if enemy.Health > 0 && !combatOver {
-		// Wrap enemy in Entity for isStunned check
-		if isStunned(enemyEntity) {
-			result += fmt.Sprintf(" %s is stunned and cannot act!", enemy.Name)
+	if enemy.Health > 0 && player.Health > 0 {
+		if status := stunningStatus(enemyEntity); status != "" {
+			log.record(CombatEvent{Type: eventTurnSkipped, Target: enemy.Name, Name: status, TargetHealth: healthOf(enemyEntity)})
 		} else {
-			enemyAttack := enemy.Strength * 2 // Basic enemy attack logic
-			player.Health -= enemyAttack
-			result += fmt.Sprintf(" %s attacks you for %d damage!", enemy.Name, enemyAttack)
+			// Carry out the telegraphed intent and pick the next one
+			enemyTakeTurn(enemy, player, log)
 		}
+		enemyEntity.tickStatuses()
 	}
 
 	// Check if player is defeated after enemy's action
 	if player.Health <= 0 {
-		result += " Player defeated! Game over."
-		combatOver = true
+		log.record(CombatEvent{Type: eventPlayerDefeated, Target: player.Name, TargetHealth: healthOf(playerEntity)})
+		return cast
 	}
 
-	return map[string]interface{}{
-		"result":        result,
-		"playerHP":      player.Health,
-		"playerMaxHP":   player.MaxHealth, // Include player max health
-		"playerMana":    player.Mana,
-		"playerMaxMana": player.MaxMana, // Include player max mana
-		"enemyHP":       enemy.Health,
-		"enemyMaxHP":    enemy.MaxHealth, // Include enemy max health
-		"enemyName":     enemy.Name,      // Include enemy name
-		"combatOver":    combatOver,
+	// Mana trickles back for the next round
+	regenMana(player, log)
+	return cast
+}
+
+// defeatEnemy records the enemy's defeat, hands out its experience and
+// leaves its loot waiting to be claimed.
+func defeatEnemy(player *Character, enemy *Enemy, log *CombatLog) {
+	log.record(CombatEvent{Type: eventEnemyDefeated, Source: player.Name, Target: enemy.Name, TargetHealth: healthOf(&enemy.Entity)})
+	gainXP(player, enemy.ExperienceReward, log)
+	if healed := restoreHealth(player, victoryHeal(player)); healed > 0 {
+		log.record(CombatEvent{Type: eventHealed, Source: player.Name, Target: player.Name, Amount: healed, Name: "Victory", TargetHealth: healthOf(&player.Entity)})
 	}
 
+	for _, reward := range rollRewards(player, enemy) {
+		player.Rewards = append(player.Rewards, reward)
+		log.record(CombatEvent{Type: eventLootDropped, Source: enemy.Name, Target: player.Name, Name: reward.describe()})
+	}
 }
 
-func StartCombatHandler(w http.ResponseWriter, r *http.Request) {
+func StartCombatHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	if r.Method != "POST" {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// Initialize enemy only if there is no active enemy or the current enemy is defeated
-	if currentEnemy == nil || currentEnemy.Health <= 0 {
-		currentEnemy = &Enemy{
-			Name:             "Goblin",
-			Health:           100,
-			MaxHealth:        100,
-			Strength:         8,
-			Dexterity:        6,
-			Intelligence:     4,
-			Armor:            2,
-			Weapon:           "Rusty Knife",
-			Level:            1,
-			ExperienceReward: 50,
-		}
+	if s.Player.Health <= 0 {
+		http.Error(w, "You have fallen, start a new run", http.StatusConflict)
+		return
 	}
 
 	// Decode action from the request
 	var actionData struct {
-		Action string `json:"action"`
-		CardID int    `json:"cardId"` // Add CardID for spell casting if needed
+		Action     string `json:"action"`
+		InstanceID string `json:"instanceId"` // The copy in hand to cast
 	}
 	err := json.NewDecoder(r.Body).Decode(&actionData)
 	if err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	switch actionData.Action {
+	case "attack", "castSpell", "start":
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	// Generate a new encounter only if there is no active enemy or the current enemy is defeated
+	if s.Enemy == nil || s.Enemy.Health <= 0 {
+		node := s.Player.Run.pendingFight()
+		if node == nil {
+			http.Error(w, "No fight here, choose a node on the map", http.StatusConflict)
+			return
+		}
+		checkpoint(s, checkpointCombatStart)
+		s.Enemy = nodeEncounter(s.Player.Run, node, s.Player.Level)
+		if s.Enemy == nil {
+			http.Error(w, "No enemies available", http.StatusInternalServerError)
+			return
+		}
+		// Every fight starts with a full mana pool
+		s.Player.Mana = s.Player.MaxMana
+		planEnemyTurn(s.Enemy, &s.Player)
+		s.CombatDeck = nil
+		s.CombatLog = newCombatLog()
+		s.CombatLog.record(CombatEvent{Type: eventCombatStarted, Source: s.Player.Name, Target: s.Enemy.Name, TargetHealth: healthOf(&s.Enemy.Entity)})
+		applyGearEffects(&s.Player, s.Enemy, s.CombatLog)
+	}
+
+	// Deal a fresh hand whenever a new fight begins
+	if s.CombatDeck == nil {
+		s.CombatDeck = newCombatDeck(s.Player.Deck)
+	}
 
 	// Execute round based on action
-	var response map[string]interface{}
+	start := len(s.CombatLog.Events)
 	switch actionData.Action {
 	case "attack":
-		response = CombatRound(&player, currentEnemy, "attack", nil)
+		CombatRound(&s.Player, s.Enemy, "attack", nil, s.CombatLog)
 	case "castSpell":
-		card := getCardByID(actionData.CardID)
-		response = CombatRound(&player, currentEnemy, "castSpell", card)
-	case "start":
-		// Setup combat
-		response = map[string]interface{}{
-			"result":        "fight started",
-			"playerHP":      player.Health,
-			"playerMaxHP":   player.MaxHealth, // Include player max health
-			"playerMana":    player.Mana,
-			"playerMaxMana": player.MaxMana, // Include player max mana
-			"enemyHP":       currentEnemy.Health,
-			"enemyMaxHP":    currentEnemy.MaxHealth, // Include enemy max health
-			"enemyName":     currentEnemy.Name,      // Include enemy name
+		instance := s.CombatDeck.InHand(actionData.InstanceID)
+		if instance == nil {
+			http.Error(w, "Card not in hand", http.StatusBadRequest)
+			return
 		}
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
-		return
+		card := instance.Card()
+		if card == nil {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		}
+		// The card only leaves the hand once it has actually been cast
+		if CombatRound(&s.Player, s.Enemy, "castSpell", card, s.CombatLog) {
+			s.CombatDeck.Play(actionData.InstanceID, card)
+		}
+	case "start":
+		// Report the whole encounter so far, including its opening event
+		start = 0
+	}
+
+	// Clear the piles once the fight is decided, otherwise draw for the next turn
+	if s.Enemy.Health <= 0 || s.Player.Health <= 0 {
+		s.endCombat()
+	} else if actionData.Action != "start" {
+		s.CombatDeck.Draw(drawPerTurn)
+	}
+	response := newCombatResponse(&s.Player, s.Enemy, s.CombatDeck, s.CombatLog, start)
+	if actionData.Action == "start" {
+		response.Result = "fight started"
 	}
 
 	// Send response to frontend
@@ -800,203 +764,132 @@ func StartCombatHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Example helper function to retrieve a card by ID (implement this based on your card management)
-func getCardByID(cardID int) *Card {
-	cards := []Card{
-		{
-			ID:       1,
-			Name:     "Fireball",
-			ManaCost: 5,
-			Type:     "spell",
-			Effects: []Effect{
-				{
-					Type:        "damage",
-					Target:      "enemy",
-					Parameters:  map[string]interface{}{"amount": 10},
-					Description: "Deals 10 damage to the enemy.",
-				},
-				{
-					Type:        "damageOverTime",
-					Target:      "enemy",
-					Parameters:  map[string]interface{}{"amount": 3, "duration": 3},
-					Description: "Burns the enemy for 3 damage over 3 turns.",
-				},
-			},
-		},
-		{
-			ID:       2,
-			Name:     "Ice Shard",
-			ManaCost: 4,
-			Type:     "spell",
-			Effects: []Effect{
-				{
-					Type:        "damage",
-					Target:      "enemy",
-					Parameters:  map[string]interface{}{"amount": 8},
-					Description: "Deals 8 damage to the enemy.",
-				},
-				{
-					Type:        "statusEffect",
-					Target:      "enemy",
-					Parameters:  map[string]interface{}{"effect": "freeze", "chance": 0.5, "duration": 3},
-					Description: "50% chance to freeze the enemy, potentially skipping their turn for 3 rounds.",
-				},
-			},
-		},
-		{
-			ID:       3,
-			Name:     "Healing Light",
-			ManaCost: 6,
-			Type:     "spell",
-			Effects: []Effect{
-				{
-					Type:        "heal",
-					Target:      "self",
-					Parameters:  map[string]interface{}{"amount": 20},
-					Description: "Heals yourself for 20 health.",
-				},
-				{
-					Type:        "healOverTime",
-					Target:      "self",
-					Parameters:  map[string]interface{}{"amount": 5, "duration": 2},
-					Description: "Heals yourself for 5 health over 2 turns.",
-				},
-			},
-		},
-		{
-			ID:       4,
-			Name:     "Shadow Strike",
-			ManaCost: 7,
-			Type:     "attack",
-			Effects: []Effect{
-				{
-					Type:        "damage",
-					Target:      "enemy",
-					Parameters:  map[string]interface{}{"amount": 12},
-					Description: "Deals 12 damage to the enemy.",
-				},
-				{
-					Type:        "buff",
-					Target:      "self",
-					Parameters:  map[string]interface{}{"stat": "attack", "modifier": 1.5, "duration": 2},
-					Description: "Increases your attack by 50% for 2 turns.",
-				},
-			},
-		},
-	}
-
-	// Search for the card with the matching ID
-	for _, card := range cards {
-		if card.ID == cardID {
-			return &card
-		}
-	}
+// applyCardEffects resolves a card's effects from the caster's point of view:
+// "self" targets the caster and "enemy" targets its opponent. Everything that
+// happens is recorded in the combat log.
+func applyCardEffects(card *Card, caster Target, opponent Target, log *CombatLog) {
+	log.record(CombatEvent{Type: eventCardPlayed, Source: caster.GetName(), Target: opponent.GetName(), Name: card.Name})
 
-	// Return nil if no card with the specified ID is found
-	return nil
+	// Spells always land, anything else can be dodged
+	applyEffects(card.Name, card.Effects, card.Type != "spell", caster, opponent, log)
 }
 
-func applyCardEffects(card *Card, player *Character, enemy *Enemy) string {
-	var result string
-
-	for _, effect := range card.Effects {
+// applyEffects resolves a list of effects from source, a card or item, and
+// records them under its name.
+func applyEffects(source string, effects []Effect, canDodge bool, caster Target, opponent Target, log *CombatLog) {
+	for _, effect := range effects {
 		var target Target
 
 		switch effect.Target {
 		case "self", "player":
-			target = player
+			target = caster
 		case "enemy":
-			target = enemy
+			target = opponent
 		default:
-			result += fmt.Sprintf("Invalid target '%s' for effect %s.", effect.Target, effect.Type)
 			continue
 		}
+		entity := target.Combatant()
 
 		switch effect.Type {
 		case "damage":
 			amount, ok := getFloatParameter(effect.Parameters, "amount")
 			if !ok {
-				result += " Invalid 'amount' parameter for damage effect."
 				continue
 			}
-			target.ReceiveDamage(int(amount))
-			result += fmt.Sprintf(" %s takes %d damage.", target.GetName(), int(amount))
+			if target == caster {
+				dealt := target.ReceiveDamage(int(amount))
+				log.record(CombatEvent{Type: eventDamageDealt, Source: caster.GetName(), Target: target.GetName(), Amount: dealt, Name: source, TargetHealth: healthOf(entity)})
+				continue
+			}
+			damageType, _ := effect.Parameters["damageType"].(string)
+			damage := caster.Combatant().modifyStat("attack", int(amount))
+			hit := resolveDamage(caster, target, damage, damageType, canDodge)
+			log.record(CombatEvent{Type: eventDamageDealt, Source: caster.GetName(), Target: target.GetName(), Amount: hit.Final, Name: source, TargetHealth: healthOf(entity), Hit: &hit})
 
 		case "heal":
 			amount, ok := getFloatParameter(effect.Parameters, "amount")
 			if !ok {
-				result += " Invalid 'amount' parameter for heal effect."
 				continue
 			}
-			newHealth := target.GetHealth() + int(amount)
-			if newHealth > target.GetMaxHealth() {
-				newHealth = target.GetMaxHealth()
-			}
-			target.SetHealth(newHealth)
-			result += fmt.Sprintf(" %s heals for %d health.", target.GetName(), int(amount))
+			healed := heal(entity, int(amount))
+			log.record(CombatEvent{Type: eventHealed, Source: caster.GetName(), Target: target.GetName(), Amount: healed, Name: source, TargetHealth: healthOf(entity)})
 
 		case "damageOverTime":
 			amount, ok := getFloatParameter(effect.Parameters, "amount")
 			duration, okDur := getFloatParameter(effect.Parameters, "duration")
 			if !ok || !okDur {
-				result += " Invalid parameters for damage over time effect."
 				continue
 			}
 			target.ApplyDoT(int(amount), int(duration))
-			result += fmt.Sprintf(" %s is afflicted with damage over time.", target.GetName())
+			log.record(CombatEvent{Type: eventDotApplied, Source: caster.GetName(), Target: target.GetName(), Amount: int(amount), Name: source, TargetHealth: healthOf(entity)})
 
 		case "healOverTime":
 			amount, ok := getFloatParameter(effect.Parameters, "amount")
 			duration, okDur := getFloatParameter(effect.Parameters, "duration")
 			if !ok || !okDur {
-				result += " Invalid parameters for heal over time effect."
 				continue
 			}
 			target.ApplyHoT(int(amount), int(duration))
-			result += fmt.Sprintf(" %s will heal over time.", target.GetName())
+			log.record(CombatEvent{Type: eventHotApplied, Source: caster.GetName(), Target: target.GetName(), Amount: int(amount), Name: source, TargetHealth: healthOf(entity)})
 
 		case "buff":
 			stat, okStat := effect.Parameters["stat"].(string)
 			modifier, okMod := getFloatParameter(effect.Parameters, "modifier")
 			duration, okDur := getFloatParameter(effect.Parameters, "duration")
 			if !okStat || !okMod || !okDur {
-				result += " Invalid parameters for buff effect."
 				continue
 			}
-			target.ApplyBuff(stat, modifier, int(duration))
-			result += fmt.Sprintf(" %s's %s is increased.", target.GetName(), stat)
+			mode, _ := effect.Parameters["mode"].(string)
+			target.ApplyBuff(stat, modifier, mode, int(duration))
+			eventType := eventBuffApplied
+			if (mode == buffAdd && modifier < 0) || (mode != buffAdd && modifier < 1) {
+				eventType = eventDebuffApplied
+			}
+			log.record(CombatEvent{Type: eventType, Source: caster.GetName(), Target: target.GetName(), Name: stat, TargetHealth: healthOf(entity)})
 
 		case "statusEffect":
 			effectName, okEffect := effect.Parameters["effect"].(string)
 			chance, okChance := getFloatParameter(effect.Parameters, "chance")
 			duration, okDur := getFloatParameter(effect.Parameters, "duration")
 			if !okEffect || !okChance || !okDur {
-				result += " Invalid parameters for status effect."
+				continue
+			}
+			// The chance is rolled once, when the status is applied
+			if rand.Float64() >= chance {
+				log.record(CombatEvent{Type: eventStatusResisted, Source: caster.GetName(), Target: target.GetName(), Name: effectName, TargetHealth: healthOf(entity)})
 				continue
 			}
 			target.ApplyStatusEffect(effectName, chance, int(duration))
-			result += fmt.Sprintf(" %s is affected by %s.", target.GetName(), effectName)
+			log.record(CombatEvent{Type: eventStatusApplied, Source: caster.GetName(), Target: target.GetName(), Name: effectName, TargetHealth: healthOf(entity)})
 
 		case "lifeSteal":
 			amount, ok := getFloatParameter(effect.Parameters, "amount")
 			if !ok {
-				result += " Invalid 'amount' parameter for lifeSteal effect."
 				continue
 			}
-			damageDealt := enemy.ReceiveDamage(int(amount))
-			playerHealth := player.GetHealth() + damageDealt
-			if playerHealth > player.GetMaxHealth() {
-				playerHealth = player.GetMaxHealth()
+			damageType, _ := effect.Parameters["damageType"].(string)
+			damage := caster.Combatant().modifyStat("attack", int(amount))
+			hit := resolveDamage(caster, opponent, damage, damageType, canDodge)
+			log.record(CombatEvent{Type: eventDamageDealt, Source: caster.GetName(), Target: opponent.GetName(), Amount: hit.Final, Name: source, TargetHealth: healthOf(opponent.Combatant()), Hit: &hit})
+			if hit.Dodged {
+				continue
 			}
-			player.SetHealth(playerHealth)
-			result += fmt.Sprintf(" %s steals %d health from %s.", player.GetName(), damageDealt, enemy.GetName())
-
-		default:
-			result += fmt.Sprintf(" Effect type %s not implemented.", effect.Type)
+			healed := heal(caster.Combatant(), hit.Final)
+			log.record(CombatEvent{Type: eventHealed, Source: caster.GetName(), Target: caster.GetName(), Amount: healed, Name: source, TargetHealth: healthOf(caster.Combatant())})
 		}
 	}
+}
 
-	return result
+// heal restores up to amount health without going over the maximum and
+// returns how much was actually restored.
+func heal(entity *Entity, amount int) int {
+	newHealth := entity.Health + amount
+	if newHealth > entity.MaxHealth {
+		newHealth = entity.MaxHealth
+	}
+	healed := newHealth - entity.Health
+	entity.Health = newHealth
+	return healed
 }
 
 func getFloatParameter(parameters map[string]interface{}, key string) (float64, bool) {
@@ -1014,25 +907,22 @@ func getFloatParameter(parameters map[string]interface{}, key string) (float64,
 	}
 }
 
-func isStunned(entity *Entity) bool {
+// stunningStatus returns the status keeping the entity from acting this turn, if any.
+func stunningStatus(entity *Entity) string {
 	for _, status := range entity.ActiveStatus {
 		if status.EffectName == "stun" || status.EffectName == "freeze" {
-			// Roll chance to determine if the effect takes place
-			if rand.Float64() < status.Chance {
-				return true
-			}
+			return status.EffectName
 		}
 	}
-	return false
+	return ""
 }
-func processOngoingEffects(entity *Entity) string {
-	var result string
 
+func processOngoingEffects(entity *Entity, log *CombatLog) {
 	// Process DoTs
 	for i := 0; i < len(entity.ActiveDoTs); {
 		dot := &entity.ActiveDoTs[i]
-		entity.Health -= dot.Amount
-		result += fmt.Sprintf(" %s takes %d damage.", entity.Name, dot.Amount)
+		dealt := entity.ReceiveDamage(dot.Amount)
+		log.record(CombatEvent{Type: eventDotTicked, Target: entity.Name, Amount: dealt, TargetHealth: healthOf(entity)})
 		dot.Duration--
 		if dot.Duration <= 0 {
 			// Remove expired DoT
@@ -1045,11 +935,8 @@ func processOngoingEffects(entity *Entity) string {
 	// Process HoTs
 	for i := 0; i < len(entity.ActiveHoTs); {
 		hot := &entity.ActiveHoTs[i]
-		entity.Health += hot.Amount
-		if entity.Health > entity.MaxHealth {
-			entity.Health = entity.MaxHealth
-		}
-		result += fmt.Sprintf(" %s heals %d health.", entity.Name, hot.Amount)
+		healed := heal(entity, hot.Amount)
+		log.record(CombatEvent{Type: eventHotTicked, Target: entity.Name, Amount: healed, TargetHealth: healthOf(entity)})
 		hot.Duration--
 		if hot.Duration <= 0 {
 			// Remove expired HoT
@@ -1059,84 +946,22 @@ func processOngoingEffects(entity *Entity) string {
 		}
 	}
 
-	// Process Buffs (if needed)
+	// Process Buffs
+	entity.tickBuffs()
+}
 
-	// Process Status Effects (e.g., reduce duration)
-	for i := 0; i < len(entity.ActiveStatus); {
-		status := &entity.ActiveStatus[i]
+// tickStatuses counts down the entity's status effects. Each side's run down
+// at the end of its own turn, so a one-round stun costs exactly one turn
+// whichever side it lands on.
+func (e *Entity) tickStatuses() {
+	for i := 0; i < len(e.ActiveStatus); {
+		status := &e.ActiveStatus[i]
 		status.Duration--
 		if status.Duration <= 0 {
 			// Remove expired status effect
-			entity.ActiveStatus = append(entity.ActiveStatus[:i], entity.ActiveStatus[i+1:]...)
+			e.ActiveStatus = append(e.ActiveStatus[:i], e.ActiveStatus[i+1:]...)
 		} else {
 			i++
 		}
 	}
-
-	return result
-}
-
-func getTarget(targetType string, player *Character, enemy *Enemy) *Entity {
-	switch targetType {
-	case "self":
-		return &Entity{
-			Name:      player.Name,
-			Health:    player.Health,
-			MaxHealth: player.MaxHealth,
-			// Add other fields if necessary
-		}
-	case "enemy":
-		return &Entity{
-			Name:      enemy.Name,
-			Health:    enemy.Health,
-			MaxHealth: enemy.MaxHealth,
-			// Add other fields if necessary
-		}
-	// Implement other target types as needed
-	default:
-		return nil
-	}
-}
-
-func UseCardHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var cardData struct {
-		CardID int `json:"cardId"`
-	}
-	err := json.NewDecoder(r.Body).Decode(&cardData)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
-		return
-	}
-
-	// Retrieve the card by ID
-	card := getCardByID(cardData.CardID)
-	if card == nil {
-		http.Error(w, "Card not found", http.StatusNotFound)
-		return
-	}
-
-	// Verify player has enough mana
-	if player.Mana < card.ManaCost {
-		http.Error(w, "Not enough mana", http.StatusBadRequest)
-		return
-	}
-
-	// Deduct mana cost
-	player.Mana -= card.ManaCost
-
-	// Apply card effects
-	result := applyCardEffects(card, &player, currentEnemy)
-
-	// Return the result of the card action
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":     fmt.Sprintf("Player uses %s: %s", card.Name, result),
-		"enemyHealth": currentEnemy.Health,
-		"playerMana":  player.Mana,
-	})
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+// TestMain loads the data catalogs the game logic looks cards, items and
+// origins up in.
+func TestMain(m *testing.M) {
+	if err := loadCardCatalog("./data/cards"); err != nil {
+		log.Fatal("Failed to load cards: ", err)
+	}
+	if err := loadItemCatalog("./data/items"); err != nil {
+		log.Fatal("Failed to load items: ", err)
+	}
+	if err := loadBestiary("./data/enemies"); err != nil {
+		log.Fatal("Failed to load enemies: ", err)
+	}
+	if err := loadRaces("./data/races"); err != nil {
+		log.Fatal("Failed to load races: ", err)
+	}
+	if err := loadClasses("./data/classes"); err != nil {
+		log.Fatal("Failed to load classes: ", err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestFireballBurnTicksThreeTimes(t *testing.T) {
+	fireball := getCardByID(1)
+	if fireball == nil {
+		t.Fatal("card 1 (Fireball) not in the catalog")
+	}
+	player := &Character{Entity: Entity{Name: "Hero", Health: 100, MaxHealth: 100}}
+	enemy := &Enemy{Entity: Entity{Name: "Goblin", Health: 1000, MaxHealth: 1000}}
+	combatLog := newCombatLog()
+
+	applyCardEffects(fireball, player, enemy, combatLog)
+	for round := 0; round < 3; round++ {
+		processOngoingEffects(&enemy.Entity, combatLog)
+	}
+	// A fourth round must not tick an expired burn
+	processOngoingEffects(&enemy.Entity, combatLog)
+
+	ticks := 0
+	for _, event := range combatLog.Events {
+		if event.Type == eventDotTicked {
+			ticks++
+		}
+	}
+	if ticks != 3 {
+		t.Errorf("burn ticked %d times, want 3", ticks)
+	}
+	if len(enemy.ActiveDoTs) != 0 {
+		t.Errorf("enemy still has %d damage-over-time effects, want none", len(enemy.ActiveDoTs))
+	}
+}
+
+func TestEnemyStunCostsPlayerOneAction(t *testing.T) {
+	player := &Character{Entity: Entity{Name: "Hero", Health: 100, MaxHealth: 100}}
+	pocketSand := Card{Name: "Pocket Sand", Type: "skill", Effects: []Effect{{
+		Type:       "statusEffect",
+		Target:     "enemy",
+		Parameters: map[string]interface{}{"effect": "stun", "chance": 1.0, "duration": 1},
+	}}}
+	wait := Card{Name: "Wait", Type: "skill"}
+	enemy := &Enemy{
+		Entity:    Entity{Name: "Bandit", Health: 1000, MaxHealth: 1000},
+		Abilities: []Card{pocketSand, wait, wait},
+		AI:        "cycle",
+	}
+	planEnemyTurn(enemy, player)
+	combatLog := newCombatLog()
+
+	// The bandit stuns the hero at the end of the first round, so the hero
+	// sits out the second and is back in the third
+	for round := 0; round < 3; round++ {
+		CombatRound(player, enemy, "attack", nil, combatLog)
+	}
+
+	acted := map[int]string{}
+	for _, event := range combatLog.Events {
+		if event.Type == eventDamageDealt && event.Source == player.Name {
+			acted[event.Round] = "attacked"
+		}
+		if event.Type == eventTurnSkipped && event.Target == player.Name {
+			acted[event.Round] = "skipped"
+		}
+	}
+	want := map[int]string{1: "attacked", 2: "skipped", 3: "attacked"}
+	for round, action := range want {
+		if acted[round] != action {
+			t.Errorf("round %d: hero %s, want %s", round, acted[round], action)
+		}
+	}
+}
@@ -0,0 +1,709 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// The database schema and the saved character format both change as the game
+// grows. Schema migrations run once each at startup, in order, and are
+// recorded in schema_migrations. Saves carry the version of the format they
+// were written in and are upgraded step by step to the current one on load.
+
+// Migration is one change to the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	Apply   func(tx *sql.Tx) error
+}
+
+// migrations in the order they are applied. Never change or reorder one that
+// has shipped; add a new one instead. The early ones check what's there, as
+// databases from before schema_migrations existed may already have them.
+var migrations = []Migration{
+	{1, "create players", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+        CREATE TABLE IF NOT EXISTS players (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT NOT NULL UNIQUE,
+            data TEXT NOT NULL
+        );
+        `)
+		return err
+	}},
+	{2, "create accounts", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+        CREATE TABLE IF NOT EXISTS accounts (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            username TEXT NOT NULL UNIQUE,
+            password_hash TEXT NOT NULL
+        );
+        `)
+		if err != nil {
+			return err
+		}
+		return keyPlayersByAccount(tx)
+	}},
+	{3, "create saves", func(tx *sql.Tx) error {
+		// Snapshots of each character: numbered save slots (1 and up) and checkpoints (slot 0)
+		_, err := tx.Exec(`
+        CREATE TABLE IF NOT EXISTS saves (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            player_id INTEGER NOT NULL REFERENCES players(id),
+            slot INTEGER NOT NULL,
+            label TEXT NOT NULL,
+            level INTEGER NOT NULL,
+            gold INTEGER NOT NULL,
+            act INTEGER NOT NULL,
+            floor INTEGER NOT NULL,
+            data TEXT NOT NULL,
+            saved_at INTEGER NOT NULL
+        );
+        `)
+		return err
+	}},
+	{4, "normalize characters", normalizeCharacters},
+}
+
+// migrate applies the migrations the database hasn't had yet.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at INTEGER NOT NULL
+    );
+    `)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`).Scan(&current)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Apply(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);`, m.Version, m.Name, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// keyPlayersByAccount rebuilds a players table from before accounts existed
+// so saves belong to an account and names only need to be unique per
+// account. Saves from before then have no owner and can't be loaded.
+func keyPlayersByAccount(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info('players');`)
+	if err != nil {
+		return err
+	}
+	hasAccount := false
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		if column == "account_id" {
+			hasAccount = true
+		}
+	}
+	rows.Close()
+	if hasAccount {
+		return nil
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE players_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            account_id INTEGER REFERENCES accounts(id),
+            name TEXT NOT NULL,
+            data TEXT NOT NULL,
+            UNIQUE (account_id, name)
+        );`,
+		`INSERT INTO players_new (id, name, data) SELECT id, name, data FROM players;`,
+		`DROP TABLE players;`,
+		`ALTER TABLE players_new RENAME TO players;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeCharacters moves the latest save of each character out of the
+// players table's JSON into the character tables, and points the save
+// history at the new character rows. Saves without an owner stay behind in
+// unowned_players. It reads and writes characters as they were when it
+// shipped, so later changes to Character or its tables don't change it.
+func normalizeCharacters(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE characters (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        account_id INTEGER REFERENCES accounts(id),
+        name TEXT NOT NULL,
+        class TEXT NOT NULL,
+        race TEXT NOT NULL,
+        level INTEGER NOT NULL,
+        xp INTEGER NOT NULL,
+        health INTEGER NOT NULL,
+        max_health INTEGER NOT NULL,
+        mana INTEGER NOT NULL,
+        max_mana INTEGER NOT NULL,
+        gold INTEGER NOT NULL,
+        stat_points INTEGER NOT NULL,
+        card_removals INTEGER NOT NULL,
+        pending TEXT NOT NULL,
+        UNIQUE (account_id, name)
+    );
+    CREATE TABLE character_stats (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        stat TEXT NOT NULL,
+        value INTEGER NOT NULL,
+        PRIMARY KEY (character_id, stat)
+    );
+    CREATE TABLE character_cards (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        position INTEGER NOT NULL,
+        instance_id TEXT NOT NULL,
+        card_id INTEGER NOT NULL,
+        upgrade INTEGER NOT NULL,
+        PRIMARY KEY (character_id, position)
+    );
+    CREATE TABLE character_equipment (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        slot TEXT NOT NULL,
+        item_id TEXT NOT NULL,
+        PRIMARY KEY (character_id, slot)
+    );
+    CREATE TABLE character_inventory (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        position INTEGER NOT NULL,
+        item_id TEXT NOT NULL,
+        PRIMARY KEY (character_id, position)
+    );
+    CREATE TABLE run_progress (
+        character_id INTEGER PRIMARY KEY REFERENCES characters(id),
+        act INTEGER NOT NULL,
+        status TEXT NOT NULL,
+        position INTEGER NOT NULL
+    );
+    CREATE TABLE run_nodes (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        node_id INTEGER NOT NULL,
+        floor INTEGER NOT NULL,
+        lane INTEGER NOT NULL,
+        type TEXT NOT NULL,
+        visited INTEGER NOT NULL,
+        cleared INTEGER NOT NULL,
+        outcome TEXT NOT NULL,
+        PRIMARY KEY (character_id, node_id)
+    );
+    CREATE TABLE run_paths (
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        from_node INTEGER NOT NULL,
+        position INTEGER NOT NULL,
+        to_node INTEGER NOT NULL,
+        PRIMARY KEY (character_id, from_node, position)
+    );
+    `)
+	if err != nil {
+		return err
+	}
+
+	type player struct {
+		id        int64
+		accountID int64
+		name      string
+		data      string
+	}
+	var players []player
+	rows, err := tx.Query(`SELECT id, account_id, name, data FROM players WHERE account_id IS NOT NULL;`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var pl player
+		if err := rows.Scan(&pl.id, &pl.accountID, &pl.name, &pl.data); err != nil {
+			rows.Close()
+			return err
+		}
+		players = append(players, pl)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+    CREATE TABLE saves_new (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        character_id INTEGER NOT NULL REFERENCES characters(id),
+        slot INTEGER NOT NULL,
+        label TEXT NOT NULL,
+        level INTEGER NOT NULL,
+        gold INTEGER NOT NULL,
+        act INTEGER NOT NULL,
+        floor INTEGER NOT NULL,
+        data TEXT NOT NULL,
+        saved_at INTEGER NOT NULL
+    );
+    `)
+	if err != nil {
+		return err
+	}
+	for _, pl := range players {
+		c, err := decodeSaveV1([]byte(pl.data))
+		if err != nil {
+			return fmt.Errorf("player %d: %w", pl.id, err)
+		}
+		characterID, err := insertCharacterV1(tx, pl.accountID, pl.name, c)
+		if err != nil {
+			return fmt.Errorf("player %d: %w", pl.id, err)
+		}
+		_, err = tx.Exec(`
+        INSERT INTO saves_new (id, character_id, slot, label, level, gold, act, floor, data, saved_at)
+        SELECT id, ?, slot, label, level, gold, act, floor, data, saved_at FROM saves WHERE player_id = ?;
+        `, characterID, pl.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range []string{
+		`DROP TABLE saves;`,
+		`ALTER TABLE saves_new RENAME TO saves;`,
+		`DELETE FROM players WHERE account_id IS NOT NULL;`,
+		`ALTER TABLE players RENAME TO unowned_players;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// characterV1 is a version 1 save, as normalizeCharacters reads it. Combat
+// effects are left out, as they were never meant to be saved.
+type characterV1 struct {
+	Name      string         `json:"name"`
+	Class     string         `json:"class"`
+	Race      string         `json:"race"`
+	Level     int            `json:"level"`
+	XP        int            `json:"xp"`
+	Health    int            `json:"health"`
+	MaxHealth int            `json:"maxHealth"`
+	Mana      int            `json:"mana"`
+	MaxMana   int            `json:"maxMana"`
+	Gold      int            `json:"gold"`
+	Stats     map[string]int `json:"stats"`
+	Deck      []struct {
+		ID      string `json:"id"`
+		CardID  int    `json:"cardId"`
+		Upgrade int    `json:"upgrade"`
+	} `json:"deck"`
+	Equipment    map[string]string `json:"equipment"`
+	Inventory    []string          `json:"inventory"`
+	StatPoints   int               `json:"statPoints"`
+	CardRemovals int               `json:"cardRemovals"`
+	Run          *runV1            `json:"run"`
+
+	// Kept on the character row as they are
+	CardPicks   json.RawMessage `json:"cardPicks"`
+	BoostOffers json.RawMessage `json:"boostOffers"`
+	Rewards     json.RawMessage `json:"rewards"`
+	Shop        json.RawMessage `json:"shop"`
+}
+
+// decodeSaveV1 reads an unversioned or version 1 save as a version 1 save.
+func decodeSaveV1(data []byte) (characterV1, error) {
+	var c characterV1
+	var save map[string]interface{}
+	if err := json.Unmarshal(data, &save); err != nil {
+		return c, err
+	}
+	version := 0
+	if v, ok := save["version"].(float64); ok {
+		version = int(v)
+	}
+	switch version {
+	case 0:
+		upgradeUnversionedSaveV1(save)
+	case 1:
+	default:
+		return c, fmt.Errorf("save version %d is newer than 1", version)
+	}
+
+	upgraded, err := json.Marshal(save)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(upgraded, &c)
+	return c, err
+}
+
+type runV1 struct {
+	Act      int         `json:"act"`
+	Status   string      `json:"status"`
+	Position int         `json:"position"`
+	Nodes    []mapNodeV1 `json:"nodes"`
+}
+
+type mapNodeV1 struct {
+	ID      int    `json:"id"`
+	Floor   int    `json:"floor"`
+	Lane    int    `json:"lane"`
+	Type    string `json:"type"`
+	Next    []int  `json:"next"`
+	Visited bool   `json:"visited"`
+	Cleared bool   `json:"cleared"`
+	Outcome string `json:"outcome"`
+}
+
+// Starter decks, starting gear and the map shape as they were when
+// migration 4 shipped. Unversioned saves are upgraded with these rather than
+// the data files, so the migration always turns out the same characters.
+var (
+	defaultStarterDeckV1 = []int{1, 1, 2, 2, 3, 3, 4, 4}
+	starterDecksV1       = map[string][]int{
+		"Warrior": {101, 101, 101, 102, 102, 103, 3, 4},
+		"Mage":    {201, 201, 201, 202, 202, 203, 1, 2},
+		"Rogue":   {301, 301, 301, 302, 302, 304, 3, 4},
+	}
+	startingGearV1 = map[string]map[string]string{
+		"Warrior": {"weapon": "training-wooden-sword", "armor": "wooden-barrel-plate"},
+		"Mage":    {"weapon": "stale-tree-branch", "armor": "old-teared-cloak"},
+		"Rogue":   {"weapon": "splintered-butter-knife", "armor": "faded-leather-jacket"},
+	}
+	nodeWeightsV1 = []struct {
+		Type   string
+		Weight int
+	}{
+		{"combat", 50},
+		{"event", 20},
+		{"elite", 12},
+		{"rest", 10},
+		{"shop", 8},
+	}
+)
+
+const (
+	mapFloorsV1     = 10
+	mapLanesV1      = 3
+	eliteMinFloorV1 = 3
+)
+
+// upgradeUnversionedSaveV1 is migration 4's own copy of
+// upgradeUnversionedSave.
+func upgradeUnversionedSaveV1(save map[string]interface{}) {
+	class, _ := save["class"].(string)
+
+	for _, key := range []string{"Name", "Health", "MaxHealth", "ActiveDoTs", "ActiveHoTs", "ActiveBuffs", "ActiveStatus"} {
+		delete(save, key)
+	}
+
+	deck, _ := save["deck"].([]interface{})
+	for i, entry := range deck {
+		if cardID, ok := entry.(float64); ok {
+			deck[i] = cardInstanceV1(int(cardID))
+		}
+	}
+	if len(deck) == 0 {
+		cardIDs, ok := starterDecksV1[class]
+		if !ok {
+			cardIDs = defaultStarterDeckV1
+		}
+		for _, cardID := range cardIDs {
+			deck = append(deck, cardInstanceV1(cardID))
+		}
+		save["deck"] = deck
+	}
+
+	delete(save, "armor")
+	delete(save, "weapon")
+	if save["equipment"] == nil {
+		equipment := map[string]string{}
+		for slot, itemID := range startingGearV1[class] {
+			equipment[slot] = itemID
+		}
+		save["equipment"] = equipment
+		save["inventory"] = []string{}
+	}
+
+	if save["run"] == nil {
+		save["run"] = runV1{Act: 1, Status: "active", Position: -1, Nodes: generateMapV1()}
+	}
+}
+
+func cardInstanceV1(cardID int) map[string]interface{} {
+	return map[string]interface{}{"id": fmt.Sprintf("%016x", rand.Uint64()), "cardId": cardID, "upgrade": 0}
+}
+
+// generateMapV1 lays out an act the way generateMap did when migration 4
+// shipped.
+func generateMapV1() []mapNodeV1 {
+	var nodes []mapNodeV1
+	for floor := 0; floor < mapFloorsV1-1; floor++ {
+		for lane := 0; lane < mapLanesV1; lane++ {
+			nodes = append(nodes, mapNodeV1{ID: len(nodes), Floor: floor, Lane: lane, Type: rollNodeTypeV1(floor), Next: []int{}})
+		}
+	}
+	boss := mapNodeV1{ID: len(nodes), Floor: mapFloorsV1 - 1, Lane: mapLanesV1 / 2, Type: "boss", Next: []int{}}
+
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Floor == mapFloorsV1-2 {
+			node.Next = append(node.Next, boss.ID)
+			continue
+		}
+		above := node.ID + mapLanesV1
+		node.Next = append(node.Next, above)
+		if rand.Intn(2) == 0 {
+			continue
+		}
+		side := node.Lane - 1 + 2*rand.Intn(2)
+		if side < 0 || side >= mapLanesV1 {
+			continue
+		}
+		if side < node.Lane && containsInt(nodes[i-1].Next, above) {
+			continue
+		}
+		node.Next = append(node.Next, above+side-node.Lane)
+	}
+	return append(nodes, boss)
+}
+
+func rollNodeTypeV1(floor int) string {
+	switch floor {
+	case 0:
+		return "combat"
+	case mapFloorsV1 - 2:
+		return "rest"
+	}
+
+	total := 0
+	for _, weight := range nodeWeightsV1 {
+		if weight.Type != "elite" || floor >= eliteMinFloorV1 {
+			total += weight.Weight
+		}
+	}
+	roll := rand.Intn(total)
+	for _, weight := range nodeWeightsV1 {
+		if weight.Type == "elite" && floor < eliteMinFloorV1 {
+			continue
+		}
+		roll -= weight.Weight
+		if roll < 0 {
+			return weight.Type
+		}
+	}
+	return "combat"
+}
+
+// insertCharacterV1 writes a version 1 save into the character tables as
+// migration 4 created them, and returns the new character's ID.
+func insertCharacterV1(tx *sql.Tx, accountID int64, name string, c characterV1) (int64, error) {
+	pending := map[string]json.RawMessage{}
+	for key, value := range map[string]json.RawMessage{
+		"cardPicks":   c.CardPicks,
+		"boostOffers": c.BoostOffers,
+		"rewards":     c.Rewards,
+		"shop":        c.Shop,
+	} {
+		if len(value) > 0 && string(value) != "null" {
+			pending[key] = value
+		}
+	}
+	pendingJSON, err := json.Marshal(pending)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+    INSERT INTO characters (account_id, name, class, race, level, xp, health, max_health, mana, max_mana, gold, stat_points, card_removals, pending)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, accountID, name, c.Class, c.Race, c.Level, c.XP, c.Health, c.MaxHealth, c.Mana, c.MaxMana, c.Gold, c.StatPoints, c.CardRemovals, string(pendingJSON))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for stat, value := range c.Stats {
+		if _, err := tx.Exec(`INSERT INTO character_stats (character_id, stat, value) VALUES (?, ?, ?);`, id, stat, value); err != nil {
+			return 0, err
+		}
+	}
+	for i, card := range c.Deck {
+		_, err := tx.Exec(`INSERT INTO character_cards (character_id, position, instance_id, card_id, upgrade) VALUES (?, ?, ?, ?, ?);`,
+			id, i, card.ID, card.CardID, card.Upgrade)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for slot, itemID := range c.Equipment {
+		if _, err := tx.Exec(`INSERT INTO character_equipment (character_id, slot, item_id) VALUES (?, ?, ?);`, id, slot, itemID); err != nil {
+			return 0, err
+		}
+	}
+	for i, itemID := range c.Inventory {
+		if _, err := tx.Exec(`INSERT INTO character_inventory (character_id, position, item_id) VALUES (?, ?, ?);`, id, i, itemID); err != nil {
+			return 0, err
+		}
+	}
+
+	if c.Run == nil {
+		return id, nil
+	}
+	_, err = tx.Exec(`INSERT INTO run_progress (character_id, act, status, position) VALUES (?, ?, ?, ?);`,
+		id, c.Run.Act, c.Run.Status, c.Run.Position)
+	if err != nil {
+		return 0, err
+	}
+	for _, node := range c.Run.Nodes {
+		_, err := tx.Exec(`
+        INSERT INTO run_nodes (character_id, node_id, floor, lane, type, visited, cleared, outcome)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+        `, id, node.ID, node.Floor, node.Lane, node.Type, node.Visited, node.Cleared, node.Outcome)
+		if err != nil {
+			return 0, err
+		}
+		for i, next := range node.Next {
+			_, err := tx.Exec(`INSERT INTO run_paths (character_id, from_node, position, to_node) VALUES (?, ?, ?, ?);`,
+				id, node.ID, i, next)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return id, nil
+}
+
+// saveVersion is the version of the save format written by this build. Bump
+// it whenever a change to Character would misread older saves, and add the
+// upgrade from the previous version to saveUpgrades.
+const saveVersion = 1
+
+// saveUpgrades turn a save of the version they are keyed by into one of the
+// next version. They work on the decoded JSON, so they can still read fields
+// Character no longer has.
+var saveUpgrades = map[int]func(save map[string]interface{}) error{
+	0: upgradeUnversionedSave,
+}
+
+// encodeSave writes a character in the current save format. Combat effects
+// only live as long as a fight and are never saved.
+func encodeSave(p Character) ([]byte, error) {
+	p.clearCombatEffects()
+	return json.Marshal(struct {
+		Version int `json:"version"`
+		Character
+	}{saveVersion, p})
+}
+
+// decodeSave reads a save of any known version into a Character.
+func decodeSave(data []byte) (Character, error) {
+	var p Character
+	var save map[string]interface{}
+	if err := json.Unmarshal(data, &save); err != nil {
+		return p, err
+	}
+
+	// Saves from before versioning have no version
+	version := 0
+	if v, ok := save["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > saveVersion {
+		return p, fmt.Errorf("save version %d is newer than this server's %d", version, saveVersion)
+	}
+	for ; version < saveVersion; version++ {
+		upgrade, ok := saveUpgrades[version]
+		if !ok {
+			return p, fmt.Errorf("no upgrade for save version %d", version)
+		}
+		if err := upgrade(save); err != nil {
+			return p, fmt.Errorf("upgrading save version %d: %w", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(save)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(upgraded, &p)
+	// Saves written before effects were stripped may still carry some
+	p.clearCombatEffects()
+	return p, err
+}
+
+// upgradeUnversionedSave brings a save from before versioning up to version 1.
+// Those saves may predate card instances, equipment or runs.
+func upgradeUnversionedSave(save map[string]interface{}) error {
+	class, _ := save["class"].(string)
+
+	// Entity's fields had no tags, so these were written next to the
+	// character's own "name", "health" and "maxHealth", usually left empty.
+	// JSON field names match regardless of case, so they have to go.
+	for _, key := range []string{"Name", "Health", "MaxHealth", "ActiveDoTs", "ActiveHoTs", "ActiveBuffs", "ActiveStatus"} {
+		delete(save, key)
+	}
+
+	// Decks were lists of card IDs before each copy had an instance
+	deck, _ := save["deck"].([]interface{})
+	for i, entry := range deck {
+		if cardID, ok := entry.(float64); ok {
+			deck[i] = newCardInstance(int(cardID))
+		}
+	}
+	if len(deck) == 0 {
+		save["deck"] = starterDeck(class)
+	}
+
+	// Gear used to be named in "armor" and "weapon"; characters from then get
+	// their class's starting gear
+	delete(save, "armor")
+	delete(save, "weapon")
+	if save["equipment"] == nil {
+		var c Character
+		if class := classCatalog.Get(class); class != nil {
+			c.equipStartingGear(class)
+		}
+		save["equipment"] = c.Equipment
+		save["inventory"] = c.Inventory
+	}
+
+	if save["run"] == nil {
+		save["run"] = newRun()
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "saves", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeUnversionedSave(t *testing.T) {
+	p, err := decodeSave(readFixture(t, "unversioned.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The character's own fields win over the empty untagged Entity ones
+	if p.Name != "Aria" || p.Health != 55 || p.MaxHealth != 60 {
+		t.Errorf("got name %q health %d/%d, want Aria 55/60", p.Name, p.Health, p.MaxHealth)
+	}
+	if p.Class != "Mage" || p.Race != "Elf" || p.Level != 2 || p.XP != 40 || p.Gold != 120 || p.Stats.Intelligence != 15 {
+		t.Errorf("character fields not carried over: %+v", p)
+	}
+
+	wantCards := []int{1, 201, 201, 2}
+	if len(p.Deck) != len(wantCards) {
+		t.Fatalf("deck has %d cards, want %d", len(p.Deck), len(wantCards))
+	}
+	ids := map[string]bool{}
+	for i, card := range p.Deck {
+		if card.CardID != wantCards[i] || card.Upgrade != 0 {
+			t.Errorf("deck[%d] = card %d upgrade %d, want card %d upgrade 0", i, card.CardID, card.Upgrade, wantCards[i])
+		}
+		if card.ID == "" || ids[card.ID] {
+			t.Errorf("deck[%d] has missing or repeated instance ID %q", i, card.ID)
+		}
+		ids[card.ID] = true
+	}
+
+	// The old armor and weapon names are replaced by the class's starting gear
+	wantGear := map[string]string{"weapon": "stale-tree-branch", "armor": "old-teared-cloak"}
+	if !reflect.DeepEqual(p.Equipment, wantGear) {
+		t.Errorf("equipment = %v, want %v", p.Equipment, wantGear)
+	}
+	if len(p.Inventory) != 0 {
+		t.Errorf("inventory = %v, want empty", p.Inventory)
+	}
+	if p.Run == nil || p.Run.Act != 1 || p.Run.Status != runActive || p.Run.Position != -1 || len(p.Run.Nodes) == 0 {
+		t.Errorf("run = %+v, want a fresh act 1 run", p.Run)
+	}
+
+	data, err := encodeSave(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var save map[string]interface{}
+	if err := json.Unmarshal(data, &save); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"armor", "weapon", "Name", "Health"} {
+		if _, ok := save[key]; ok {
+			t.Errorf("re-encoded save still has %q", key)
+		}
+	}
+}
+
+// version1Character is the character in testdata/saves/version1.json.
+func version1Character() Character {
+	return Character{
+		Entity:  Entity{Name: "Borin", Health: 70, MaxHealth: 80},
+		Class:   "Warrior",
+		Race:    "Dwarf",
+		Level:   3,
+		XP:      150,
+		Mana:    20,
+		MaxMana: 25,
+		Gold:    45,
+		Stats: Stats{
+			Strength: 15, Dexterity: 10, Intelligence: 10, Endurance: 13,
+			Perception: 10, Wisdom: 10, Agility: 10, Luck: 10,
+		},
+		Deck: []CardInstance{
+			{ID: "00000000000000a1", CardID: 101, Upgrade: 1},
+			{ID: "00000000000000a2", CardID: 101},
+			{ID: "00000000000000a3", CardID: 3},
+		},
+		Equipment:    map[string]string{"weapon": "iron-sword", "armor": "wooden-barrel-plate"},
+		Inventory:    []string{"chainmail"},
+		StatPoints:   1,
+		CardPicks:    [][]int{{102, 103, 4}},
+		CardRemovals: 1,
+		Run: &Run{
+			Act:    1,
+			Status: runActive,
+			Nodes: []MapNode{
+				{ID: 0, Floor: 0, Lane: 0, Type: nodeCombat, Next: []int{2}, Visited: true, Cleared: true},
+				{ID: 1, Floor: 0, Lane: 1, Type: nodeCombat, Next: []int{2, 3}},
+				{ID: 2, Floor: 1, Lane: 0, Type: nodeRest, Next: []int{}},
+				{ID: 3, Floor: 1, Lane: 1, Type: nodeShop, Next: []int{}},
+			},
+			Position: 0,
+		},
+	}
+}
+
+func TestDecodeVersion1Save(t *testing.T) {
+	p, err := decodeSave(readFixture(t, "version1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The buff left over from a fight is dropped along the way
+	if want := version1Character(); !reflect.DeepEqual(p, want) {
+		t.Errorf("decodeSave =\n%+v\nwant\n%+v", p, want)
+	}
+}
+
+// schemaBeforeMigrations is the database as the server left it before
+// schema migrations were tracked.
+const schemaBeforeMigrations = `
+CREATE TABLE accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL
+);
+CREATE TABLE players (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER REFERENCES accounts(id),
+    name TEXT NOT NULL,
+    data TEXT NOT NULL,
+    UNIQUE (account_id, name)
+);
+CREATE TABLE saves (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    player_id INTEGER NOT NULL REFERENCES players(id),
+    slot INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    level INTEGER NOT NULL,
+    gold INTEGER NOT NULL,
+    act INTEGER NOT NULL,
+    floor INTEGER NOT NULL,
+    data TEXT NOT NULL,
+    saved_at INTEGER NOT NULL
+);
+`
+
+func TestMigrateSaveSlotsDatabase(t *testing.T) {
+	testDB, err := openDatabase(filepath.Join(t.TempDir(), "game.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+
+	borin := string(readFixture(t, "version1.json"))
+	aria := string(readFixture(t, "unversioned.json"))
+	for _, stmt := range []string{
+		schemaBeforeMigrations,
+		`INSERT INTO accounts (id, username, password_hash) VALUES (7, 'player', 'hash');`,
+		`INSERT INTO players (id, account_id, name, data) VALUES (1, NULL, 'Nobody', '{"name":"Nobody","class":"Rogue"}');`,
+		`INSERT INTO players (id, account_id, name, data) VALUES (2, 7, 'Borin', '` + borin + `');`,
+		`INSERT INTO players (id, account_id, name, data) VALUES (3, 7, 'Aria', '` + aria + `');`,
+		`INSERT INTO saves (id, player_id, slot, label, level, gold, act, floor, data, saved_at)
+         VALUES (10, 2, 1, 'Slot 1', 3, 45, 1, 1, '` + borin + `', 1000);`,
+		`INSERT INTO saves (id, player_id, slot, label, level, gold, act, floor, data, saved_at)
+         VALUES (11, 2, 0, 'Combat start', 3, 45, 1, 1, '` + borin + `', 1001);`,
+		`INSERT INTO saves (id, player_id, slot, label, level, gold, act, floor, data, saved_at)
+         VALUES (12, 1, 1, 'Slot 1', 1, 0, 0, 0, '{"name":"Nobody","class":"Rogue"}', 1002);`,
+	} {
+		if _, err := testDB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrate(testDB); err != nil {
+		t.Fatal(err)
+	}
+	// Running it again finds nothing left to do
+	if err := migrate(testDB); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+
+	var version int
+	if err := testDB.QueryRow(`SELECT MAX(version) FROM schema_migrations;`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 4 {
+		t.Errorf("schema version %d, want 4", version)
+	}
+
+	repo := &CharacterRepository{db: testDB}
+	got, err := repo.Load(7, "Borin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := version1Character(); !reflect.DeepEqual(got, want) {
+		t.Errorf("migrated Borin =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// The unversioned save is upgraded with the starter deck, gear and map
+	// as they were when the migration shipped
+	got, err = repo.Load(7, "Aria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Aria" || got.Class != "Mage" || got.Race != "Elf" || got.Level != 2 || got.XP != 40 ||
+		got.Health != 55 || got.MaxHealth != 60 || got.Mana != 30 || got.MaxMana != 35 || got.Gold != 120 {
+		t.Errorf("migrated Aria = %+v", got)
+	}
+	wantStats := Stats{Strength: 10, Dexterity: 10, Intelligence: 15, Endurance: 10, Perception: 10, Wisdom: 10, Agility: 10, Luck: 13}
+	if got.Stats != wantStats {
+		t.Errorf("stats = %+v, want %+v", got.Stats, wantStats)
+	}
+	var cards []int
+	for _, card := range got.Deck {
+		if len(card.ID) != 16 || card.Upgrade != 0 {
+			t.Errorf("card instance %+v, want a 16 digit ID and no upgrade", card)
+		}
+		cards = append(cards, card.CardID)
+	}
+	if want := []int{1, 201, 201, 2}; !reflect.DeepEqual(cards, want) {
+		t.Errorf("deck = %v, want %v", cards, want)
+	}
+	if want := map[string]string{"weapon": "stale-tree-branch", "armor": "old-teared-cloak"}; !reflect.DeepEqual(got.Equipment, want) {
+		t.Errorf("equipment = %v, want %v", got.Equipment, want)
+	}
+	if len(got.Inventory) != 0 {
+		t.Errorf("inventory = %v, want empty", got.Inventory)
+	}
+	run := got.Run
+	if run == nil || run.Act != 1 || run.Status != "active" || run.Position != -1 || len(run.Nodes) != 28 {
+		t.Fatalf("run = %+v, want act 1 with 28 nodes and no position", run)
+	}
+	for _, node := range run.Nodes[:27] {
+		if node.Floor != node.ID/3 || node.Lane != node.ID%3 || len(node.Next) == 0 {
+			t.Errorf("node %+v out of place", node)
+		}
+	}
+	if boss := run.Nodes[27]; boss.Floor != 9 || boss.Lane != 1 || boss.Type != "boss" || len(boss.Next) != 0 {
+		t.Errorf("boss node = %+v", boss)
+	}
+
+	// The save history follows the character
+	var saves int
+	err = testDB.QueryRow(`
+    SELECT COUNT(*) FROM saves JOIN characters ON characters.id = saves.character_id
+    WHERE characters.account_id = 7 AND characters.name = 'Borin' AND saves.id IN (10, 11);
+    `).Scan(&saves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saves != 2 {
+		t.Errorf("%d saves point at the migrated character, want 2", saves)
+	}
+
+	// The ownerless save is set aside rather than given to anyone
+	var unowned string
+	if err := testDB.QueryRow(`SELECT name FROM unowned_players;`).Scan(&unowned); err != nil {
+		t.Fatal(err)
+	}
+	if unowned != "Nobody" {
+		t.Errorf("unowned player %q, want Nobody", unowned)
+	}
+	var characters int
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM characters;`).Scan(&characters); err != nil {
+		t.Fatal(err)
+	}
+	if characters != 2 {
+		t.Errorf("%d characters, want 2", characters)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Trait is a passive bonus that comes with a race or class. It changes an
+// effective stat the same way a permanent buff would, and can also grant
+// resistances.
+type Trait struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	StatModifier
+	Resistances map[string]float64 `json:"resistances,omitempty"`
+}
+
+// Race defines a playable race. Its name is what characters store.
+type Race struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	BaseStats   Stats   `json:"baseStats"`
+	Traits      []Trait `json:"traits,omitempty"`
+}
+
+// Class defines a playable class. Its name is what characters store.
+type Class struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	StatBonuses  Stats    `json:"statBonuses"`  // Added on top of the race's base stats
+	StartingGear []string `json:"startingGear"` // Item IDs equipped on new characters
+	StartingDeck []int    `json:"startingDeck"`
+	Traits       []Trait  `json:"traits,omitempty"`
+}
+
+// RaceCatalog holds every race definition known to the server.
+type RaceCatalog struct {
+	races map[string]Race
+	order []string
+}
+
+// ClassCatalog holds every class definition known to the server.
+type ClassCatalog struct {
+	classes map[string]Class
+	order   []string
+}
+
+var raceCatalog = &RaceCatalog{races: make(map[string]Race)}
+var classCatalog = &ClassCatalog{classes: make(map[string]Class)}
+
+// loadRaces reads every race file in dir and replaces the catalog once all
+// of them have been validated.
+func loadRaces(dir string) error {
+	races, err := readDataDir[Race](dir)
+	if err != nil {
+		return err
+	}
+
+	catalog := &RaceCatalog{races: make(map[string]Race)}
+	for _, race := range races {
+		if race.Name == "" {
+			return fmt.Errorf("race without a name")
+		}
+		if _, exists := catalog.races[race.Name]; exists {
+			return fmt.Errorf("duplicate race %q", race.Name)
+		}
+		for _, trait := range race.Traits {
+			if err := validateTrait(trait); err != nil {
+				return fmt.Errorf("race %q: %w", race.Name, err)
+			}
+		}
+		catalog.races[race.Name] = race
+		catalog.order = append(catalog.order, race.Name)
+	}
+
+	raceCatalog = catalog
+	return nil
+}
+
+// loadClasses reads every class file in dir and replaces the catalog once
+// all of them have been validated. Cards and items must be loaded first.
+func loadClasses(dir string) error {
+	classes, err := readDataDir[Class](dir)
+	if err != nil {
+		return err
+	}
+
+	catalog := &ClassCatalog{classes: make(map[string]Class)}
+	for _, class := range classes {
+		if class.Name == "" {
+			return fmt.Errorf("class without a name")
+		}
+		if _, exists := catalog.classes[class.Name]; exists {
+			return fmt.Errorf("duplicate class %q", class.Name)
+		}
+		if len(class.StartingDeck) == 0 {
+			return fmt.Errorf("class %q has no starting deck", class.Name)
+		}
+		for _, id := range class.StartingDeck {
+			card := getCardByID(id)
+			if card == nil {
+				return fmt.Errorf("class %q starts with unknown card %d", class.Name, id)
+			}
+			if !card.usableBy(class.Name) {
+				return fmt.Errorf("class %q starts with %s card %d", class.Name, card.Class, id)
+			}
+		}
+		slots := map[string]bool{}
+		for _, id := range class.StartingGear {
+			item := itemCatalog.Get(id)
+			if item == nil {
+				return fmt.Errorf("class %q starts with unknown item %q", class.Name, id)
+			}
+			if slots[item.Slot] {
+				return fmt.Errorf("class %q starts with two %s items", class.Name, item.Slot)
+			}
+			slots[item.Slot] = true
+		}
+		for _, trait := range class.Traits {
+			if err := validateTrait(trait); err != nil {
+				return fmt.Errorf("class %q: %w", class.Name, err)
+			}
+		}
+		catalog.classes[class.Name] = class
+		catalog.order = append(catalog.order, class.Name)
+	}
+
+	for _, card := range cardCatalog.All() {
+		if _, ok := catalog.classes[card.Class]; card.Class != "" && !ok {
+			return fmt.Errorf("card %d belongs to unknown class %q", card.ID, card.Class)
+		}
+	}
+
+	classCatalog = catalog
+	return nil
+}
+
+func validateTrait(trait Trait) error {
+	if trait.Name == "" {
+		return fmt.Errorf("trait without a name")
+	}
+	if trait.Stat == "" && len(trait.Resistances) == 0 {
+		return fmt.Errorf("trait %q changes nothing", trait.Name)
+	}
+	if trait.Stat != "" {
+		if err := validateStatModifier(trait.StatModifier); err != nil {
+			return fmt.Errorf("trait %q: %w", trait.Name, err)
+		}
+	}
+	for damageType := range trait.Resistances {
+		if !damageTypes[damageType] {
+			return fmt.Errorf("trait %q resists unknown damage type %q", trait.Name, damageType)
+		}
+	}
+	return nil
+}
+
+// Get returns the race with the given name, or nil if there is none.
+func (c *RaceCatalog) Get(name string) *Race {
+	race, ok := c.races[name]
+	if !ok {
+		return nil
+	}
+	return &race
+}
+
+// All returns every race in the order they were defined.
+func (c *RaceCatalog) All() []Race {
+	races := make([]Race, 0, len(c.order))
+	for _, name := range c.order {
+		races = append(races, c.races[name])
+	}
+	return races
+}
+
+// Get returns the class with the given name, or nil if there is none.
+func (c *ClassCatalog) Get(name string) *Class {
+	class, ok := c.classes[name]
+	if !ok {
+		return nil
+	}
+	return &class
+}
+
+// All returns every class in the order they were defined.
+func (c *ClassCatalog) All() []Class {
+	classes := make([]Class, 0, len(c.order))
+	for _, name := range c.order {
+		classes = append(classes, c.classes[name])
+	}
+	return classes
+}
+
+// starterDeck returns fresh copies of the class's starting deck, falling
+// back to the default deck for classes that are no longer defined.
+func starterDeck(className string) []CardInstance {
+	if class := classCatalog.Get(className); class != nil {
+		return newCardInstances(class.StartingDeck)
+	}
+	return newCardInstances(defaultStarterDeck)
+}
+
+// traits returns the passive traits the character gets from its race and class.
+func (c *Character) traits() []Trait {
+	var traits []Trait
+	if race := raceCatalog.Get(c.Race); race != nil {
+		traits = append(traits, race.Traits...)
+	}
+	if class := classCatalog.Get(c.Class); class != nil {
+		traits = append(traits, class.Traits...)
+	}
+	return traits
+}
+
+// permanentBuffs expresses the character's traits and equipped items as
+// buffs that never run out.
+func (c *Character) permanentBuffs() []Buff {
+	var modifiers []StatModifier
+	for _, trait := range c.traits() {
+		modifiers = append(modifiers, trait.StatModifier)
+	}
+	for _, item := range c.equippedItems() {
+		modifiers = append(modifiers, item.Modifiers...)
+	}
+	return modifierBuffs(modifiers)
+}
+
+func RacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(raceCatalog.All())
+}
+
+func ClassesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classCatalog.All())
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Total XP needed to reach each level; xpThresholds[i] is the requirement for
+// level i+1. Characters stop levelling at the end of the table.
+var xpThresholds = []int{0, 100, 250, 450, 700, 1000, 1350, 1750, 2200, 2700}
+
+var maxLevel = len(xpThresholds)
+
+// Rewards granted for every level gained
+const (
+	healthPerLevel     = 10
+	manaPerLevel       = 5
+	statPointsPerLevel = 3
+	cardPickChoices    = 3
+)
+
+// maxHealthFor derives maximum health from Endurance and level.
+func maxHealthFor(c *Character) int {
+	return c.Stats.Endurance*10 + (c.Level-1)*healthPerLevel
+}
+
+// maxManaFor derives maximum mana from Intelligence and level.
+func maxManaFor(c *Character) int {
+	return c.Stats.Intelligence*5 + (c.Level-1)*manaPerLevel
+}
+
+// xpForNextLevel returns the total XP needed for the character's next level,
+// or 0 once the character is at the maximum level.
+func xpForNextLevel(c *Character) int {
+	if c.Level >= maxLevel {
+		return 0
+	}
+	return xpThresholds[c.Level]
+}
+
+// gainXP hands out experience and applies every level-up it pays for.
+func gainXP(player *Character, amount int, log *CombatLog) {
+	player.XP += amount
+	log.record(CombatEvent{Type: eventXPGained, Target: player.Name, Amount: amount})
+
+	for next := xpForNextLevel(player); next > 0 && player.XP >= next; next = xpForNextLevel(player) {
+		levelUp(player)
+		log.record(CombatEvent{Type: eventLevelUp, Target: player.Name, Amount: player.Level, TargetHealth: healthOf(&player.Entity)})
+	}
+}
+
+// levelUp raises the character one level. The health and mana gained are
+// added to the current values too, and the character gets stat points to
+// spend, a stat boost offer and a card pick.
+func levelUp(c *Character) {
+	oldMaxHealth, oldMaxMana := c.MaxHealth, c.MaxMana
+
+	c.Level++
+	c.MaxHealth = maxHealthFor(c)
+	c.MaxMana = maxManaFor(c)
+	c.Health += c.MaxHealth - oldMaxHealth
+	c.Mana += c.MaxMana - oldMaxMana
+
+	c.StatPoints += statPointsPerLevel
+	grantStatBoostOffers(c, statBoostOffersPerLevel)
+	if pick := offerCardPick(c.Class); len(pick) > 0 {
+		c.CardPicks = append(c.CardPicks, pick)
+	}
+}
+
+// offerCardPick draws distinct cards from the class's card pool for the
+// player to choose from. Rarer cards are less likely to be offered.
+func offerCardPick(class string) []int {
+	return cardIDs(drawByRarity(cardCatalog.Pool(class), cardPickChoices, rarityWeights, cardRarity))
+}
+
+func ProgressionHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	next := xpForNextLevel(&s.Player)
+	toNext := 0
+	if next > 0 {
+		toNext = next - s.Player.XP
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"level":          s.Player.Level,
+		"maxLevel":       maxLevel,
+		"xp":             s.Player.XP,
+		"xpForNextLevel": next,   // 0 at the maximum level
+		"xpToNextLevel":  toNext, // 0 at the maximum level
+		"statPoints":     s.Player.StatPoints,
+		"cardPicks":      s.Player.CardPicks,
+	})
+}
+
+// SpendStatPointsHandler puts unspent level-up points into a stat.
+func SpendStatPointsHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var spendData struct {
+		Stat   string `json:"stat"`
+		Points int    `json:"points"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&spendData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if statPointer(&s.Player.Stats, spendData.Stat) == nil {
+		http.Error(w, "Invalid stat", http.StatusBadRequest)
+		return
+	}
+	if spendData.Points < 1 || spendData.Points > s.Player.StatPoints {
+		http.Error(w, "Not enough stat points", http.StatusBadRequest)
+		return
+	}
+
+	s.Player.StatPoints -= spendData.Points
+	applyStatBoost(&s.Player, spendData.Stat, spendData.Points)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
+
+// PickCardHandler resolves the oldest pending card pick. Sending cardId 0
+// skips the pick without adding a card.
+func PickCardHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't change deck during combat", http.StatusConflict)
+		return
+	}
+
+	var pickData struct {
+		CardID int `json:"cardId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&pickData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if len(s.Player.CardPicks) == 0 {
+		http.Error(w, "No card pick available", http.StatusBadRequest)
+		return
+	}
+
+	if pickData.CardID != 0 {
+		offered := false
+		for _, id := range s.Player.CardPicks[0] {
+			if id == pickData.CardID {
+				offered = true
+				break
+			}
+		}
+		if !offered {
+			http.Error(w, "Card not offered", http.StatusBadRequest)
+			return
+		}
+		s.Player.addCard(pickData.CardID)
+	}
+	s.Player.CardPicks = s.Player.CardPicks[1:]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
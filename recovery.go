@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Recovery rules. Mana is topped up when a fight begins and trickles back
+// each round, faster with Wisdom. Health only comes back outside of a fight's
+// rounds: a little after every victory and a lot more at rest sites, both
+// growing with Endurance.
+const (
+	manaRegenBase           = 1
+	wisdomPerManaRegen      = 4 // Each this many points of Wisdom add one mana per round
+	victoryHealPerEndurance = 1
+	restHealPercent         = 30 // Share of max health restored by resting
+	endurancePerRestPercent = 2  // Each this many points of Endurance add a percent
+)
+
+// manaRegen is how much mana the character recovers at the end of a round.
+func manaRegen(c *Character) int {
+	return manaRegenBase + c.EffectiveStat("wisdom")/wisdomPerManaRegen
+}
+
+// victoryHeal is how much health the character catches back after a won fight.
+func victoryHeal(c *Character) int {
+	return c.EffectiveStat("endurance") * victoryHealPerEndurance
+}
+
+// restHeal is how much health resting at a rest site restores.
+func restHeal(c *Character) int {
+	percent := min(restHealPercent+c.EffectiveStat("endurance")/endurancePerRestPercent, 100)
+	return c.MaxHealth * percent / 100
+}
+
+// restoreHealth heals the character up to its max health and returns how
+// much was actually restored.
+func restoreHealth(c *Character, amount int) int {
+	healed := max(min(amount, c.MaxHealth-c.Health), 0)
+	c.Health += healed
+	return healed
+}
+
+// restoreMana refills the character's mana up to its max and returns how
+// much was actually restored.
+func restoreMana(c *Character, amount int) int {
+	restored := max(min(amount, c.MaxMana-c.Mana), 0)
+	c.Mana += restored
+	return restored
+}
+
+// regenMana recovers the character's mana at the end of a round.
+func regenMana(player *Character, log *CombatLog) {
+	if restored := restoreMana(player, manaRegen(player)); restored > 0 {
+		log.record(CombatEvent{Type: eventManaRestored, Target: player.Name, Amount: restored, TargetHealth: healthOf(&player.Entity)})
+	}
+}
+
+// RestHandler acts on the rest site the character is standing at: resting
+// heals a share of max health that grows with Endurance, or the time can be
+// spent upgrading one card instead.
+func RestHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var restData struct {
+		Action     string `json:"action"`               // "heal" or "upgrade"
+		InstanceID string `json:"instanceId,omitempty"` // The copy to upgrade
+	}
+	err := json.NewDecoder(r.Body).Decode(&restData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	node := s.Player.Run.current()
+	if node == nil || node.Type != nodeRest {
+		http.Error(w, "No rest site here", http.StatusConflict)
+		return
+	}
+	if node.Cleared {
+		http.Error(w, "Already rested here", http.StatusConflict)
+		return
+	}
+
+	switch restData.Action {
+	case "heal":
+		healed := restoreHealth(&s.Player, restHeal(&s.Player))
+		node.Outcome = fmt.Sprintf("You rest and recover %d health.", healed)
+	case "upgrade":
+		instance := s.Player.deckCard(restData.InstanceID)
+		if instance == nil {
+			http.Error(w, "Card not in deck", http.StatusBadRequest)
+			return
+		}
+		if !instance.canUpgrade() {
+			http.Error(w, "Card can't be upgraded", http.StatusBadRequest)
+			return
+		}
+		instance.Upgrade++
+		node.Outcome = fmt.Sprintf("You hone your %s.", instance.Card().Name)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	node.Cleared = true
+
+	writeMap(w, s.Player.Run)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+)
+
+// After a victory the enemy's loot table is rolled and a card choice is
+// offered. Rewards wait on the character until claimed, so they survive a
+// refresh or a save and load.
+
+const (
+	lootRollsPerVictory = 2
+	rewardCardChoices   = 3
+)
+
+// How much each point of Luck adds to the uncommon and rare card weights.
+const (
+	uncommonWeightPerLuck = 1.0
+	rareWeightPerLuck     = 0.5
+)
+
+// Reward is one unclaimed spoil of a fight.
+type Reward struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"` // "gold", "item", "card" or "cardChoice"
+	Gold    int    `json:"gold,omitempty"`
+	ItemID  string `json:"itemId,omitempty"`
+	CardID  int    `json:"cardId,omitempty"`
+	Choices []int  `json:"choices,omitempty"` // Card IDs offered by a "cardChoice"
+}
+
+// describe names the reward for the combat log.
+func (r Reward) describe() string {
+	switch r.Type {
+	case "gold":
+		return fmt.Sprintf("%d gold", r.Gold)
+	case "item":
+		if item := itemCatalog.Get(r.ItemID); item != nil {
+			return item.Name
+		}
+	case "card":
+		if card := getCardByID(r.CardID); card != nil {
+			return card.Name
+		}
+	case "cardChoice":
+		return "a choice of cards"
+	}
+	return "something"
+}
+
+// luckyRarityWeights shifts card rarity weights towards rarer cards as Luck grows.
+func luckyRarityWeights(luck int) map[string]int {
+	weights := make(map[string]int, len(rarityWeights))
+	for rarity, weight := range rarityWeights {
+		weights[rarity] = weight
+	}
+	weights["uncommon"] += int(uncommonWeightPerLuck * float64(luck))
+	weights["rare"] += int(rareWeightPerLuck * float64(luck))
+	return weights
+}
+
+// rollLoot picks an entry from the loot table by weight.
+func rollLoot(table []LootEntry) *LootEntry {
+	total := 0
+	for _, loot := range table {
+		total += loot.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	roll := rand.Intn(total)
+	for i := range table {
+		roll -= table[i].Weight
+		if roll < 0 {
+			return &table[i]
+		}
+	}
+	return nil
+}
+
+// rollRewards rolls the spoils for beating enemy.
+func rollRewards(player *Character, enemy *Enemy) []Reward {
+	var rewards []Reward
+	for i := 0; i < lootRollsPerVictory; i++ {
+		loot := rollLoot(enemy.LootTable)
+		if loot == nil {
+			break
+		}
+		switch loot.Type {
+		case "gold":
+			rewards = append(rewards, Reward{Type: "gold", Gold: loot.Min + rand.Intn(loot.Max-loot.Min+1)})
+		case "item":
+			rewards = append(rewards, Reward{Type: "item", ItemID: loot.ItemID})
+		case "card":
+			rewards = append(rewards, Reward{Type: "card", CardID: loot.CardID})
+		}
+	}
+
+	weights := luckyRarityWeights(player.EffectiveStat("luck"))
+	choices := drawByRarity(cardCatalog.Pool(player.Class), rewardCardChoices, weights, cardRarity)
+	if len(choices) > 0 {
+		rewards = append(rewards, Reward{Type: "cardChoice", Choices: cardIDs(choices)})
+	}
+
+	for i := range rewards {
+		rewards[i].ID = fmt.Sprintf("%016x", rand.Uint64())
+	}
+	return rewards
+}
+
+func RewardsHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rewards := s.Player.Rewards
+	if rewards == nil {
+		rewards = []Reward{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rewards)
+}
+
+// ClaimRewardHandler takes one pending reward. Card choices need the chosen
+// cardId, or 0 to pass on all of them.
+func ClaimRewardHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var claimData struct {
+		RewardID string `json:"rewardId"`
+		CardID   int    `json:"cardId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&claimData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	index := -1
+	for i, reward := range s.Player.Rewards {
+		if reward.ID == claimData.RewardID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		http.Error(w, "Reward not found", http.StatusNotFound)
+		return
+	}
+	reward := s.Player.Rewards[index]
+
+	if reward.Type == "card" || (reward.Type == "cardChoice" && claimData.CardID != 0) {
+		if s.CombatDeck != nil {
+			http.Error(w, "Can't change deck during combat", http.StatusConflict)
+			return
+		}
+	}
+	if reward.Type == "cardChoice" && claimData.CardID != 0 {
+		offered := false
+		for _, id := range reward.Choices {
+			if id == claimData.CardID {
+				offered = true
+				break
+			}
+		}
+		if !offered {
+			http.Error(w, "Card not offered", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch reward.Type {
+	case "gold":
+		s.Player.Gold += reward.Gold
+	case "item":
+		s.Player.Inventory = append(s.Player.Inventory, reward.ItemID)
+	case "card":
+		s.Player.addCard(reward.CardID)
+	case "cardChoice":
+		if claimData.CardID != 0 {
+			s.Player.addCard(claimData.CardID)
+		}
+	}
+	s.Player.Rewards = append(s.Player.Rewards[:index], s.Player.Rewards[index+1:]...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Every saved character keeps a history of snapshots. The player saves into
+// numbered slots, and the server takes checkpoints of its own when a fight
+// starts or the character moves on the map. Any snapshot can be restored.
+// The latest manual save is also what loading the character by name returns.
+
+const (
+	saveSlots       = 3
+	checkpointsKept = 10 // Older checkpoints are dropped
+	checkpointSlot  = 0  // Checkpoints don't take up a numbered slot
+)
+
+// Checkpoint labels.
+const (
+	checkpointCombatStart = "Combat start"
+	checkpointNodeChoice  = "Node choice"
+)
+
+// SaveSummary describes a snapshot without loading it.
+type SaveSummary struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Slot    int       `json:"slot"` // 0 for checkpoints
+	Label   string    `json:"label"`
+	Level   int       `json:"level"`
+	Gold    int       `json:"gold"`
+	Act     int       `json:"act"`
+	Floor   int       `json:"floor"` // 0 before the first floor of the act
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// runProgress returns the act and floor the character has reached.
+func runProgress(c Character) (act, floor int) {
+	if c.Run == nil {
+		return 0, 0
+	}
+	if node := c.Run.current(); node != nil {
+		floor = node.Floor + 1
+	}
+	return c.Run.Act, floor
+}
+
+// Save stores a snapshot of the character for the account. Saves into a
+// numbered slot replace whatever was in it and become the character's latest
+// save; checkpoints are added to the history and the oldest ones dropped.
+func (repo *CharacterRepository) Save(accountID int64, p Character, slot int, label string) error {
+	data, err := encodeSave(p)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Checkpoints only store the character if it has never been saved
+	characterID, err := findCharacter(tx, accountID, p.Name)
+	if slot != checkpointSlot || err == sql.ErrNoRows {
+		characterID, err = saveCharacter(tx, accountID, p)
+	}
+	if err != nil {
+		return err
+	}
+
+	if slot != checkpointSlot {
+		if _, err := tx.Exec(`DELETE FROM saves WHERE character_id = ? AND slot = ?;`, characterID, slot); err != nil {
+			return err
+		}
+	}
+	act, floor := runProgress(p)
+	_, err = tx.Exec(`
+    INSERT INTO saves (character_id, slot, label, level, gold, act, floor, data, saved_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, characterID, slot, label, p.Level, p.Gold, act, floor, string(data), time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if slot == checkpointSlot {
+		_, err = tx.Exec(`
+        DELETE FROM saves WHERE character_id = ? AND slot = ? AND id NOT IN (
+            SELECT id FROM saves WHERE character_id = ? AND slot = ? ORDER BY id DESC LIMIT ?
+        );
+        `, characterID, checkpointSlot, characterID, checkpointSlot, checkpointsKept)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// claimCharacter adds a character made before logging in to the account, so
+// saving it can't overwrite one the account already has by that name. It
+// returns errCharacterExists if the account does.
+func claimCharacter(s *Session) error {
+	if s.Claimed {
+		return nil
+	}
+	if err := characterRepo.Create(s.AccountID, s.Player); err != nil {
+		return err
+	}
+	s.Claimed = true
+	return nil
+}
+
+// checkpoint snapshots the session's character for a logged-in account. A
+// failed checkpoint is logged but doesn't get in the way of playing.
+func checkpoint(s *Session, label string) {
+	if s.AccountID == 0 || s.Player.Name == "" {
+		return
+	}
+	err := claimCharacter(s)
+	if err == nil {
+		err = characterRepo.Save(s.AccountID, s.Player, checkpointSlot, label)
+	}
+	if err != nil {
+		log.Printf("Checkpoint for %s failed: %v", s.Player.Name, err)
+	}
+}
+
+// Saves returns the account's snapshots of a character, newest first.
+func (repo *CharacterRepository) Saves(accountID int64, name string) ([]SaveSummary, error) {
+	rows, err := repo.db.Query(`
+    SELECT saves.id, characters.name, saves.slot, saves.label, saves.level, saves.gold, saves.act, saves.floor, saves.saved_at
+    FROM saves JOIN characters ON characters.id = saves.character_id
+    WHERE characters.account_id = ? AND characters.name = ?
+    ORDER BY saves.id DESC;
+    `, accountID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saves := []SaveSummary{}
+	for rows.Next() {
+		var save SaveSummary
+		var savedAt int64
+		err := rows.Scan(&save.ID, &save.Name, &save.Slot, &save.Label, &save.Level, &save.Gold, &save.Act, &save.Floor, &savedAt)
+		if err != nil {
+			return nil, err
+		}
+		save.SavedAt = time.Unix(savedAt, 0).UTC()
+		saves = append(saves, save)
+	}
+	return saves, rows.Err()
+}
+
+// LoadSave reads one of the account's snapshots.
+func (repo *CharacterRepository) LoadSave(accountID, saveID int64) (Character, error) {
+	var p Character
+	var data string
+	err := repo.db.QueryRow(`
+    SELECT saves.data FROM saves JOIN characters ON characters.id = saves.character_id
+    WHERE saves.id = ? AND characters.account_id = ?;
+    `, saveID, accountID).Scan(&data)
+	if err != nil {
+		return p, err
+	}
+	return decodeSave([]byte(data))
+}
+
+// resumeCharacter binds a loaded character to the session. Whatever fight
+// was going on in the session is dropped.
+func resumeCharacter(s *Session, p Character) {
+	s.Player = p
+	s.Claimed = true
+	s.Enemy = nil
+	s.CombatDeck = nil
+}
+
+// ListSavesHandler lists the snapshots of a character, by default the one
+// being played.
+func ListSavesHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.AccountID == 0 {
+		http.Error(w, "Log in to see saves", http.StatusUnauthorized)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = s.Player.Name
+	}
+	if name == "" {
+		http.Error(w, "Player name is required", http.StatusBadRequest)
+		return
+	}
+
+	saves, err := characterRepo.Saves(s.AccountID, name)
+	if err != nil {
+		http.Error(w, "Error listing saves", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"slots": saveSlots,
+		"saves": saves,
+	})
+}
+
+// RestoreSaveHandler puts the character back the way one of its snapshots
+// has it. The restored state isn't saved until the player saves again.
+func RestoreSaveHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.AccountID == 0 {
+		http.Error(w, "Log in to load", http.StatusUnauthorized)
+		return
+	}
+
+	var restoreData struct {
+		SaveID int64 `json:"saveId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&restoreData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	loadedPlayer, err := characterRepo.LoadSave(s.AccountID, restoreData.SaveID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Save not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Error loading progress", http.StatusInternalServerError)
+		}
+		return
+	}
+	resumeCharacter(s, loadedPlayer)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header used to carry the session token between the client and the server.
+// A header (rather than a cookie) keeps every browser tab on its own session.
+const sessionHeader = "X-Session-Token"
+
+// Sessions that have not been touched for this long are dropped.
+const sessionTTL = 2 * time.Hour
+
+// Session holds the game state that belongs to a single client.
+type Session struct {
+	mu sync.Mutex
+
+	ID         string
+	AccountID  int64 // 0 until the caller logs in
+	Username   string
+	Player     Character
+	Claimed    bool // Player was created for or loaded from AccountID
+	Enemy      *Enemy
+	CombatDeck *CombatDeck
+	CombatLog  *CombatLog
+	lastSeen   time.Time
+}
+
+// SessionStore maps session tokens to their game state.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+var sessions = NewSessionStore()
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: make(map[string]*Session)}
+}
+
+// Get returns the session for the given token, or nil if it is unknown or expired.
+func (st *SessionStore) Get(id string) *Session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Since(s.lastSeen) > sessionTTL {
+		delete(st.sessions, id)
+		return nil
+	}
+	s.lastSeen = time.Now()
+	return s
+}
+
+// Create registers a new, empty session and returns it.
+func (st *SessionStore) Create() (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.prune()
+	s := &Session{ID: id, lastSeen: time.Now()}
+	st.sessions[id] = s
+	return s, nil
+}
+
+// prune removes expired sessions. The caller must hold st.mu.
+func (st *SessionStore) prune() {
+	for id, s := range st.sessions {
+		if time.Since(s.lastSeen) > sessionTTL {
+			delete(st.sessions, id)
+		}
+	}
+}
+
+// Delete drops a session so its token stops working.
+func (st *SessionStore) Delete(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, id)
+}
+
+// Login binds an account to a brand new session and writes its token to the
+// response. The game state of the caller's current session, if any, moves
+// over and the old token stops working, so a token is never reused across a
+// login.
+func (st *SessionStore) Login(w http.ResponseWriter, r *http.Request, accountID int64, username string) (*Session, error) {
+	s, err := st.Create()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := st.FromRequest(r); old != nil {
+		old.mu.Lock()
+		// A different account's character stays behind
+		if old.AccountID == 0 || old.AccountID == accountID {
+			s.Player = old.Player
+			s.Claimed = old.Claimed
+			s.Enemy = old.Enemy
+			s.CombatDeck = old.CombatDeck
+			s.CombatLog = old.CombatLog
+		}
+		old.mu.Unlock()
+		st.Delete(old.ID)
+	}
+
+	s.AccountID = accountID
+	s.Username = username
+	w.Header().Set(sessionHeader, s.ID)
+	return s, nil
+}
+
+// FromRequest looks up the session referenced by the request's token.
+func (st *SessionStore) FromRequest(r *http.Request) *Session {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return nil
+	}
+	return st.Get(id)
+}
+
+// Issue returns the caller's current session, or creates a new one, and
+// writes its token to the response so the client can send it back.
+func (st *SessionStore) Issue(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	s := st.FromRequest(r)
+	if s == nil {
+		var err error
+		s, err = st.Create()
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Header().Set(sessionHeader, s.ID)
+	return s, nil
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// endCombat clears the piles and temporary effects once a fight is decided
+// and settles the fight's node on the map.
+func (s *Session) endCombat() {
+	s.CombatDeck = nil
+	s.Player.clearCombatEffects()
+	s.Player.Run.finishFight(s.Player.Health > 0)
+}
+
+// Session middleware: resolves the caller's session and holds its lock for
+// the whole request so concurrent requests from one client can't race.
+func withSession(next func(http.ResponseWriter, *http.Request, *Session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := sessions.FromRequest(r)
+		if s == nil {
+			http.Error(w, "No active session", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		next(w, r, s)
+	}
+}
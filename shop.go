@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Merchant stock and prices. Cards are priced by rarity, items by their value.
+const (
+	shopCardOffers        = 5
+	shopItemOffers        = 3
+	cardRemovalBasePrice  = 75
+	cardRemovalPriceRaise = 25 // Added to the removal price after each removal
+	cardUpgradePrice      = 100
+)
+
+var cardPrices = map[string]int{
+	"common":   50,
+	"uncommon": 80,
+	"rare":     150,
+}
+
+// Shop is the merchant's stock for one visit. It is stored with the
+// character so reloading can't reroll it.
+type Shop struct {
+	Offers       []ShopOffer `json:"offers"`
+	RemovalPrice int         `json:"removalPrice"`
+	RemovalUsed  bool        `json:"removalUsed"` // One card removal per visit
+	UpgradePrice int         `json:"upgradePrice"`
+}
+
+// ShopOffer is one card or item for sale.
+type ShopOffer struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"` // "card" or "item"
+	CardID int    `json:"cardId,omitempty"`
+	ItemID string `json:"itemId,omitempty"`
+	Price  int    `json:"price"`
+	Sold   bool   `json:"sold"`
+}
+
+// newShop rolls a fresh stock from the character's card pool and the item catalog.
+func newShop(c *Character) *Shop {
+	shop := &Shop{
+		Offers:       []ShopOffer{},
+		RemovalPrice: cardRemovalBasePrice + cardRemovalPriceRaise*c.CardRemovals,
+		UpgradePrice: cardUpgradePrice,
+	}
+	for _, card := range drawByRarity(cardCatalog.Pool(c.Class), shopCardOffers, rarityWeights, cardRarity) {
+		shop.Offers = append(shop.Offers, ShopOffer{
+			ID:     fmt.Sprintf("card-%d", len(shop.Offers)+1),
+			Type:   "card",
+			CardID: card.ID,
+			Price:  cardPrices[card.Rarity],
+		})
+	}
+	itemRarity := func(item Item) string { return item.Rarity }
+	for _, item := range drawByRarity(itemCatalog.All(), shopItemOffers, rarityWeights, itemRarity) {
+		shop.Offers = append(shop.Offers, ShopOffer{
+			ID:     fmt.Sprintf("item-%d", len(shop.Offers)+1),
+			Type:   "item",
+			ItemID: item.ID,
+			Price:  item.Value,
+		})
+	}
+	return shop
+}
+
+// visitShop returns the stock of the merchant the character is standing
+// at, or nil away from a shop node.
+func visitShop(c *Character) *Shop {
+	if !c.Run.at(nodeShop) {
+		return nil
+	}
+	if c.Shop == nil {
+		c.Shop = newShop(c)
+	}
+	return c.Shop
+}
+
+func ShopHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shop := visitShop(&s.Player)
+	if shop == nil {
+		http.Error(w, "No merchant here", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shop": shop,
+		"gold": s.Player.Gold,
+	})
+}
+
+func BuyHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't shop during combat", http.StatusConflict)
+		return
+	}
+
+	var buyData struct {
+		OfferID string `json:"offerId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&buyData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	shop := visitShop(&s.Player)
+	if shop == nil {
+		http.Error(w, "No merchant here", http.StatusConflict)
+		return
+	}
+	var offer *ShopOffer
+	for i := range shop.Offers {
+		if shop.Offers[i].ID == buyData.OfferID {
+			offer = &shop.Offers[i]
+			break
+		}
+	}
+	if offer == nil {
+		http.Error(w, "Offer not found", http.StatusNotFound)
+		return
+	}
+	if offer.Sold {
+		http.Error(w, "Already sold", http.StatusConflict)
+		return
+	}
+	if s.Player.Gold < offer.Price {
+		http.Error(w, "Not enough gold", http.StatusBadRequest)
+		return
+	}
+
+	s.Player.Gold -= offer.Price
+	offer.Sold = true
+	switch offer.Type {
+	case "card":
+		s.Player.addCard(offer.CardID)
+	case "item":
+		s.Player.Inventory = append(s.Player.Inventory, offer.ItemID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
+
+// RemoveCardHandler pays the merchant to take one card out of the deck.
+func RemoveCardHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't shop during combat", http.StatusConflict)
+		return
+	}
+
+	var removeData struct {
+		InstanceID string `json:"instanceId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&removeData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	shop := visitShop(&s.Player)
+	if shop == nil {
+		http.Error(w, "No merchant here", http.StatusConflict)
+		return
+	}
+	if shop.RemovalUsed {
+		http.Error(w, "Card removal already used", http.StatusConflict)
+		return
+	}
+	if s.Player.deckCard(removeData.InstanceID) == nil {
+		http.Error(w, "Card not in deck", http.StatusBadRequest)
+		return
+	}
+	if len(s.Player.Deck) == 1 {
+		http.Error(w, "Deck can't be empty", http.StatusBadRequest)
+		return
+	}
+	if s.Player.Gold < shop.RemovalPrice {
+		http.Error(w, "Not enough gold", http.StatusBadRequest)
+		return
+	}
+
+	s.Player.Gold -= shop.RemovalPrice
+	s.Player.removeCard(removeData.InstanceID)
+	s.Player.CardRemovals++
+	shop.RemovalUsed = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
+
+// UpgradeCardHandler pays the merchant to upgrade one copy in the deck.
+func UpgradeCardHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.CombatDeck != nil {
+		http.Error(w, "Can't shop during combat", http.StatusConflict)
+		return
+	}
+
+	var upgradeData struct {
+		InstanceID string `json:"instanceId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&upgradeData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	shop := visitShop(&s.Player)
+	if shop == nil {
+		http.Error(w, "No merchant here", http.StatusConflict)
+		return
+	}
+	instance := s.Player.deckCard(upgradeData.InstanceID)
+	if instance == nil {
+		http.Error(w, "Card not in deck", http.StatusBadRequest)
+		return
+	}
+	if !instance.canUpgrade() {
+		http.Error(w, "Card can't be upgraded", http.StatusBadRequest)
+		return
+	}
+	if s.Player.Gold < shop.UpgradePrice {
+		http.Error(w, "Not enough gold", http.StatusBadRequest)
+		return
+	}
+
+	s.Player.Gold -= shop.UpgradePrice
+	instance.Upgrade++
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+)
+
+// Stat boost offers are rolled and stored on the server. The client only
+// chooses which stored offer to claim and which stat it goes into, and a
+// claimed offer is removed so it can't be redeemed twice.
+
+const (
+	minStatBoost            = 1
+	maxStatBoost            = 4
+	startingStatBoostOffers = 1 // Granted when a character is created
+	statBoostOffersPerLevel = 1
+)
+
+type StatBoostOffer struct {
+	ID    string `json:"id"`
+	Boost int    `json:"boost"`
+}
+
+// grantStatBoostOffers rolls count new offers for the character. IDs only
+// need to be unique within one character's offers.
+func grantStatBoostOffers(c *Character, count int) {
+	for i := 0; i < count; i++ {
+		c.BoostOffers = append(c.BoostOffers, StatBoostOffer{
+			ID:    fmt.Sprintf("%016x", rand.Uint64()),
+			Boost: rand.Intn(maxStatBoost-minStatBoost+1) + minStatBoost,
+		})
+	}
+}
+
+// claimStatBoostOffer removes the offer with the given ID and returns it.
+func claimStatBoostOffer(c *Character, id string) (StatBoostOffer, bool) {
+	for i, offer := range c.BoostOffers {
+		if offer.ID == id {
+			c.BoostOffers = append(c.BoostOffers[:i], c.BoostOffers[i+1:]...)
+			return offer, true
+		}
+	}
+	return StatBoostOffer{}, false
+}
+
+func StatBoostsHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offers := s.Player.BoostOffers
+	if offers == nil {
+		offers = []StatBoostOffer{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offers)
+}
+
+// ClaimStatBoostHandler redeems one pending offer into the chosen stat.
+func ClaimStatBoostHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var claimData struct {
+		OfferID    string `json:"offerId"`
+		ChosenStat string `json:"chosenStat"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&claimData)
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if statPointer(&s.Player.Stats, claimData.ChosenStat) == nil {
+		http.Error(w, "Invalid stat", http.StatusBadRequest)
+		return
+	}
+
+	offer, ok := claimStatBoostOffer(&s.Player, claimData.OfferID)
+	if !ok {
+		http.Error(w, "Offer not found", http.StatusNotFound)
+		return
+	}
+	applyStatBoost(&s.Player, claimData.ChosenStat, offer.Boost)
+
+	// Respond with the updated character data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Player)
+}